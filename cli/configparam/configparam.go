@@ -29,6 +29,12 @@ func (p *configParam[T]) GetName() string {
 	return p.Name
 }
 
+// GetFlagName returns the name of the command line flag of the
+// configparam value.
+func (p *configParam[T]) GetFlagName() string {
+	return p.FlagName
+}
+
 // WithShortName sets the short name of the command line flag that can
 // be used to configure the parameter value.
 func (p *configParam[T]) WithShortName(shortName string) *T {
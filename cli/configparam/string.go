@@ -40,6 +40,12 @@ func SensitiveString(name, description string) *StringParam {
 	return stringGenerator(name, description, true)
 }
 
+// IsSensitive reports whether the value of the parameter is masked
+// when it is printed.
+func (p *StringParam) IsSensitive() bool {
+	return p.sensitive
+}
+
 // AttachToCommand registers the persistent string flag (long form and
 // optional short form) with the supplied cobra.Command.
 func (p *StringParam) AttachToCommand(command *cobra.Command) {
@@ -61,6 +61,12 @@ func (p *StringSliceParam) WithPossibleValuesFn(fn func() ([]string, error)) *St
 	return p
 }
 
+// IsSensitive reports whether the values of the parameter are masked
+// when they are printed.
+func (p *StringSliceParam) IsSensitive() bool {
+	return p.sensitive
+}
+
 // AttachToCommand registers the persistent string-slice flag (long form and
 // optional short form) with the supplied [cobra.Command].
 func (p *StringSliceParam) AttachToCommand(command *cobra.Command) {
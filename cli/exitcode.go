@@ -0,0 +1,33 @@
+package cli
+
+import "errors"
+
+// exitCodeError wraps an error returned by a subcommand with the exit
+// code of the CLI tool.
+type exitCodeError struct {
+	error
+	code int
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.error
+}
+
+// WithExitCode wraps err so that the CLI tool exits with the given
+// code when err is returned by the Run function of a subcommand.
+func WithExitCode(err error, code int) error {
+	return &exitCodeError{
+		error: err,
+		code:  code,
+	}
+}
+
+// ExitCode returns the exit code set for err with [WithExitCode]. It
+// returns 1 if no exit code is set.
+func ExitCode(err error) int {
+	var ece *exitCodeError
+	if errors.As(err, &ece) {
+		return ece.code
+	}
+	return 1
+}
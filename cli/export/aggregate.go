@@ -0,0 +1,104 @@
+package export
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// warningGroup counts the messages that share a severity, a text
+// and the values of the grouping attributes.
+type warningGroup struct {
+	severity severity
+	message  string
+	attrs    []any
+	count    int
+}
+
+// warningAggregator groups the reported infos, warnings and errors
+// so that only the first limit messages of each group are printed.
+// The number of the suppressed messages is printed in the summary.
+type warningAggregator struct {
+	groupBy []string
+	limit   int
+	groups  map[string]*warningGroup
+	order   []string
+}
+
+func newWarningAggregator(groupBy []string, limit int) *warningAggregator {
+	return &warningAggregator{
+		groupBy: groupBy,
+		limit:   limit,
+		groups:  map[string]*warningGroup{},
+	}
+}
+
+// newWarningAggregatorFromParams returns the warningAggregator
+// configured by the warning aggregation parameters, or nil if the
+// aggregation is disabled.
+func newWarningAggregatorFromParams() *warningAggregator {
+	if !AggregateWarningsParam.Value() {
+		return nil
+	}
+	return newWarningAggregator(WarningGroupByParam.Value(), max(WarningLimitParam.Value(), 0))
+}
+
+// add records msg and reports whether it shall be printed.
+func (a *warningAggregator) add(msg message) bool {
+	if a == nil {
+		return true
+	}
+	err := msg.err
+	attrs := errorAttributes(err)
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "%s\x00%s", msg.severity, err.Error())
+	groupAttrs := []any{}
+	for _, key := range a.groupBy {
+		value, ok := attrs[key]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(sb, "\x00%s=%v", key, value)
+		groupAttrs = append(groupAttrs, key, value)
+	}
+	key := sb.String()
+	group, ok := a.groups[key]
+	if !ok {
+		group = &warningGroup{
+			severity: msg.severity,
+			message:  err.Error(),
+			attrs:    groupAttrs,
+		}
+		a.groups[key] = group
+		a.order = append(a.order, key)
+	}
+	group.count++
+	return group.count <= a.limit
+}
+
+// summary logs the number of occurrences of the messages that were
+// not printed each time.
+func (a *warningAggregator) summary(logger *slog.Logger) {
+	if a == nil {
+		return
+	}
+	for _, key := range a.order {
+		group := a.groups[key]
+		if group.count <= a.limit {
+			continue
+		}
+		args := append([]any{
+			"occurrences", group.count,
+			"suppressed", group.count - a.limit,
+		}, group.attrs...)
+		text := fmt.Sprintf("Repeated %s: %s", group.severity, group.message)
+		switch group.severity {
+		case severityInfo:
+			logger.Info(text, args...)
+		case severityError:
+			logger.Error(text, args...)
+		default:
+			logger.Warn(text, args...)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/erratt"
+)
+
+var _ = Describe("The warning aggregator", func() {
+	warning := func(err error) message {
+		return message{severity: severityWarning, err: err}
+	}
+
+	It("prints the first occurrences of a message", func() {
+		a := newWarningAggregator(nil, 2)
+		Expect(a.add(warning(errors.New("slow API")))).To(BeTrue())
+		Expect(a.add(warning(errors.New("slow API")))).To(BeTrue())
+		Expect(a.add(warning(errors.New("slow API")))).To(BeFalse())
+		Expect(a.add(warning(errors.New("no owner")))).To(BeTrue())
+	})
+
+	It("distinguishes the severities", func() {
+		a := newWarningAggregator(nil, 1)
+		Expect(a.add(warning(errors.New("slow API")))).To(BeTrue())
+		Expect(a.add(message{severity: severityError, err: errors.New("slow API")})).To(BeTrue())
+	})
+
+	It("groups by the configured attributes", func() {
+		a := newWarningAggregator([]string{"kind"}, 1)
+		Expect(a.add(warning(erratt.New("no owner", "kind", "Bucket", "name", "logs")))).To(BeTrue())
+		Expect(a.add(warning(erratt.New("no owner", "kind", "Bucket", "name", "backups")))).To(BeFalse())
+		Expect(a.add(warning(erratt.New("no owner", "kind", "DNSZone", "name", "logs")))).To(BeTrue())
+	})
+
+	It("logs the suppressed messages in the summary", func() {
+		buf := &bytes.Buffer{}
+		a := newWarningAggregator([]string{"kind"}, 1)
+		for range 3 {
+			a.add(warning(erratt.New("no owner", "kind", "Bucket")))
+		}
+		a.add(message{severity: severityError, err: errors.New("timeout")})
+		a.add(message{severity: severityError, err: errors.New("timeout")})
+		a.add(message{severity: severityInfo, err: errors.New("skipped")})
+		a.summary(newTextLogger(buf))
+		Expect(logLines(buf)).To(Equal([]string{
+			`level=WARN msg="Repeated warning: no owner" occurrences=3 suppressed=2 kind=Bucket`,
+			`level=ERROR msg="Repeated error: timeout" occurrences=2 suppressed=1`,
+		}))
+	})
+
+	It("prints every message when the aggregation is disabled", func() {
+		setParam(AggregateWarningsParam, false)
+		a := newWarningAggregatorFromParams()
+		Expect(a).To(BeNil())
+		Expect(a.add(warning(errors.New("slow API")))).To(BeTrue())
+		a.summary(nil)
+	})
+
+	It("counts the suppressed messages in the result of the run", func() {
+		setParam(WarningLimitParam, 1)
+		buf := &bytes.Buffer{}
+		p := newTestPipeline(&recordingOutput{})
+		p.aggregator = newWarningAggregatorFromParams()
+		p.logger = newTextLogger(buf)
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			for range 3 {
+				events.Warn(errors.New("slow API"))
+			}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.result.Warnings).To(Equal(3))
+		Expect(logLines(buf)).To(Equal([]string{
+			`level=WARN msg="slow API"`,
+			`level=WARN msg="Repeated warning: slow API" occurrences=3 suppressed=2`,
+			`level=INFO msg="Export finished" resources=0 infos=0 warnings=3 errors=0`,
+		}))
+	})
+})
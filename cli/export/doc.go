@@ -23,10 +23,44 @@ In case of non-fatal errors, the errors can be reported using the
 
 method. The reported errors are printed on the console to STDERR.
 
+Warnings that concern a single resource can be reported using the
+
+	WarnResource(resource.Object, error)
+
+method before the resource is reported. The warning is added as a
+comment to the YAML document of the resource, which is commented out
+if the warning is marked with [Blocking].
+
+Notable but normal events, such as skipped resources, can be reported
+using the
+
+	Info(error)
+
+method. Non-fatal errors, such as resources that cannot be exported,
+can be reported using the
+
+	Error(error)
+
+method. The export continues, but the subcommand exits with
+[ExitCodePartialFailure].
+
+The business logic can report how many resources of a kind are
+processed using the
+
+	Progress(kind string, done, total int)
+
+method. A total less than or equal to zero means that the total is
+unknown.
+
 The business logic can signal that the processing is stopped using the
 
 	Stop()
 
 method.
+
+Methods may be added to the [EventHandler] interface in later
+versions, which breaks the types that implement it outside of this
+module, like the fakes of tests. Test export functions with the
+recorder of the exporttest package instead.
 */
 package export
@@ -0,0 +1,59 @@
+package export
+
+import (
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+)
+
+// EncryptFields configures the export subcommand to encrypt the
+// values of the fields with the given names, like the fields that
+// hold the values of sensitive configuration parameters. The export
+// fails if no age recipients are configured, so that the values are
+// never written in plain text. The fields are selected by name, not
+// by path, wherever they are in the resources, see [yaml.Encrypter]:
+//
+//	export.EncryptFields("password", "token")
+func EncryptFields(fields ...string) {
+	exportCmd.encryptedFields = append(exportCmd.encryptedFields, fields...)
+}
+
+// newEncrypter returns the encrypter configured by the
+// AgeRecipientsParam, the EncryptFieldsParam and encryptedFields, or
+// nil if neither age recipients nor fields to encrypt are configured.
+func newEncrypter(encryptedFields []string) (*yaml.Encrypter, erratt.Error) {
+	recipients := AgeRecipientsParam.Value()
+	fields := append(EncryptFieldsParam.Value(), encryptedFields...)
+	switch {
+	case len(recipients) == 0 && len(fields) > 0:
+		return nil, erratt.New("Encrypting fields requires age recipients, use --age-recipients", "fields", fields)
+	case len(recipients) == 0:
+		return nil, nil
+	case len(fields) == 0:
+		return nil, erratt.New("No field to encrypt is configured, use --encrypt-fields")
+	case OutputParam.Value() == "":
+		return nil, erratt.New("Encrypting fields requires an output file")
+	case OutputTemplateParam.Value() != "" || JSONPathParam.Value() != "":
+		return nil, erratt.New("Encrypting fields is supported with the YAML output only")
+	case AppendParam.Value():
+		return nil, erratt.New("The append and age-recipients options cannot be used together")
+	}
+	e, err := yaml.NewEncrypter(recipients, fields...)
+	if err != nil {
+		return nil, erratt.Errorf("Cannot set up encryption: %w", err)
+	}
+	return e, nil
+}
+
+// reportUnusedFields warns about the fields to encrypt of which no
+// value has been encrypted, like misspelled field names.
+func (p *pipeline) reportUnusedFields() {
+	if p.encrypter == nil {
+		return
+	}
+	for _, field := range p.encrypter.UnusedFields() {
+		p.report(message{
+			severity: severityWarning,
+			err:      erratt.New("No value of the field to encrypt has been found", "field", field),
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package export
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"filippo.io/age"
+)
+
+var _ = Describe("newEncrypter", func() {
+	var recipient string
+	BeforeEach(func() {
+		identity, err := age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		recipient = identity.Recipient().String()
+		setParam(OutputParam, "out.yaml")
+	})
+
+	It("returns no encrypter without recipients and fields", func() {
+		Expect(newEncrypter(nil)).To(BeNil())
+	})
+
+	It("rejects the fields registered with EncryptFields without recipients", func() {
+		_, err := newEncrypter([]string{"password"})
+		Expect(err).To(MatchError(ContainSubstring("Encrypting fields requires age recipients")))
+	})
+
+	It("rejects the fields of the encrypt-fields parameter without recipients", func() {
+		setParam(EncryptFieldsParam, []string{"token"})
+		_, err := newEncrypter(nil)
+		Expect(err).To(MatchError(ContainSubstring("Encrypting fields requires age recipients")))
+	})
+
+	It("rejects recipients without fields", func() {
+		setParam(AgeRecipientsParam, []string{recipient})
+		_, err := newEncrypter(nil)
+		Expect(err).To(MatchError(ContainSubstring("No field to encrypt")))
+	})
+
+	It("requires an output file", func() {
+		setParam(AgeRecipientsParam, []string{recipient})
+		setParam(OutputParam, "")
+		_, err := newEncrypter([]string{"password"})
+		Expect(err).To(MatchError("Encrypting fields requires an output file"))
+	})
+
+	It("rejects field paths", func() {
+		setParam(AgeRecipientsParam, []string{recipient})
+		setParam(EncryptFieldsParam, []string{"spec.forProvider.password"})
+		_, err := newEncrypter(nil)
+		Expect(err).To(MatchError(ContainSubstring("must be a field name, not a path")))
+	})
+
+	It("warns about the fields of which no value has been encrypted", func() {
+		setParam(AgeRecipientsParam, []string{recipient})
+		e, err := newEncrypter([]string{"password"})
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		p := newTestPipeline(&recordingOutput{})
+		p.logger = newTextLogger(buf)
+		p.encrypter = e
+		runErr := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newResource("Bucket", "logs"))
+			return nil
+		})
+		Expect(runErr).NotTo(HaveOccurred())
+		Expect(p.result.Warnings).To(Equal(1))
+		Expect(logLines(buf)).To(ContainElement(`level=WARN msg="No value of the field to encrypt has been found" field=password`))
+	})
+
+	It("encrypts the registered fields and the fields of the parameter", func() {
+		setParam(AgeRecipientsParam, []string{recipient})
+		setParam(EncryptFieldsParam, []string{"token"})
+		e, err := newEncrypter([]string{"password"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(e).NotTo(BeNil())
+	})
+})
@@ -2,30 +2,31 @@ package export
 
 import (
 	"context"
-	"fmt"
+	"io"
 	"log/slog"
 	"os"
-	"path/filepath"
 	"sync"
+	"sync/atomic"
 
+	"github.com/SAP/xp-clifford/cli/export/internal/warning"
 	"github.com/SAP/xp-clifford/erratt"
 	"github.com/SAP/xp-clifford/yaml"
 
 	"github.com/charmbracelet/log"
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
-func printErrors(ctx context.Context, wg *sync.WaitGroup, errChan <-chan error) {
+func printMessages(ctx context.Context, wg *sync.WaitGroup, p *pipeline, messageChan <-chan message) {
 	defer wg.Done()
-	errlog := slog.New(log.NewWithOptions(os.Stdout, log.Options{}))
 	for {
 		select {
-		case err, ok := <-errChan:
+		case msg, ok := <-messageChan:
 			if !ok {
-				// error channel is closed
+				// message channel is closed
 				return
 			}
-			erratt.SlogWarnWith(err, errlog)
+			p.report(msg)
 		case <-ctx.Done():
 			// execution is cancelled
 			return
@@ -33,21 +34,219 @@ func printErrors(ctx context.Context, wg *sync.WaitGroup, errChan <-chan error)
 	}
 }
 
-func openOutput() (*os.File, erratt.Error) {
-	var fileOutput *os.File
-	if o := OutputParam.Value(); o != "" {
-		var err error
-		fileOutput, err = os.Create(filepath.Clean(o))
+// pipeline holds the stages that the reported resources pass
+// through before reaching the output.
+type pipeline struct {
+	scheme     *runtime.Scheme
+	filter     filterFunc
+	validator  *crdValidator
+	normalizer *yaml.Normalizer
+	encrypter  *yaml.Encrypter
+	render     renderFunc
+	out        output
+	events     *eventLog
+	progress   progressDisplay
+	warnings   *warning.Tracker
+	aggregator *warningAggregator
+	logger     *slog.Logger
+	mu         sync.Mutex
+	result     runResult
+	// snapshot records the exported resources in watch mode.
+	snapshot snapshot
+	// committed is set when the output is committed.
+	committed bool
+}
+
+// newPipeline creates the pipeline of an export run configured by the
+// config parameters of the export subcommand. Typed resources are
+// checked against the scheme of c, if it is not nil. The events of
+// the run are recorded in events.
+func newPipeline(c *exportSubCommand, events *eventLog, replaceable ...string) (*pipeline, erratt.Error) {
+	p, err := newStages(c, events)
+	if err != nil {
+		return nil, err
+	}
+	encrypter, err := newEncrypter(c.encryptedFields)
+	if err != nil {
+		return nil, err
+	}
+	out, err := openOutput(encrypter, replaceable...)
+	if err != nil {
+		return nil, err
+	}
+	render, err := newRenderer(out)
+	if err != nil {
+		_ = out.Abort()
+		return nil, err
+	}
+	p.encrypter = encrypter
+	p.render = render
+	p.out = out
+	p.progress = newProgressDisplay(p.logger, out)
+	if bars, ok := p.progress.(*barDisplay); ok {
+		// the log lines are printed above the progress bars
+		p.logger = newLogger(bars)
+	}
+	return p, nil
+}
+
+// newStages creates a pipeline without output, with the filter,
+// validation and normalization stages configured by the config
+// parameters of the export subcommand.
+func newStages(c *exportSubCommand, events *eventLog) (*pipeline, erratt.Error) {
+	filter, err := newFilter()
+	if err != nil {
+		return nil, err
+	}
+	validator, err := newCRDValidatorFromParams()
+	if err != nil {
+		return nil, err
+	}
+	normalizer, err := newNormalizer(c.removedFields)
+	if err != nil {
+		return nil, err
+	}
+	return &pipeline{
+		scheme:     c.scheme,
+		filter:     filter,
+		validator:  validator,
+		normalizer: normalizer,
+		events:     events,
+		warnings:   warning.NewTracker(),
+		aggregator: newWarningAggregatorFromParams(),
+		logger:     newLogger(os.Stderr),
+	}, nil
+}
+
+// newLogger returns a logger writing to w at the level of the default
+// logger.
+func newLogger(w io.Writer) *slog.Logger {
+	level := log.InfoLevel
+	if slog.Default().Enabled(context.Background(), slog.LevelDebug) {
+		level = log.DebugLevel
+	}
+	return slog.New(log.NewWithOptions(w, log.Options{Level: level}))
+}
+
+// report logs msg, records it in the event stream and counts it in
+// the result of the run.
+func (p *pipeline) report(msg message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.result.count(msg)
+	if p.aggregator.add(msg) {
+		msg.log(p.logger)
+	}
+	p.events.message(msg)
+}
+
+// validate reports the CRD schema violations of res as warnings
+// concerning res.
+func (p *pipeline) validate(res resource.Object) {
+	if p.validator == nil {
+		return
+	}
+	violations, err := p.validator.validate(res)
+	if err != nil {
+		violations = []error{err}
+	}
+	for _, violation := range violations {
+		p.warnings.Add(res, violation)
+		p.report(message{
+			severity: severityWarning,
+			err:      resourceWarning(res, violation),
+		})
+	}
+}
+
+func (p *pipeline) process(res resource.Object) {
+	if err := setGroupVersionKind(p.scheme, res); err != nil {
+		p.warnings.Take(res)
+		p.report(message{
+			severity: severityWarning,
+			err:      erratt.Errorf("Resource is rejected: %w", err).With("name", res.GetName()),
+		})
+		return
+	}
+	accepted, err := p.filter(res)
+	if err != nil {
+		p.report(message{
+			severity: severityWarning,
+			err:      resourceWarning(res, err),
+		})
+	}
+	if !accepted {
+		p.warnings.Take(res)
+		return
+	}
+	p.validate(res)
+	res = p.warnings.Annotate(res)
+	if p.normalizer != nil {
+		normalized, err := p.normalizer.NormalizeResource(res)
 		if err != nil {
-			return nil, erratt.Errorf("Cannot create output file: %w", err).With("output", o)
+			p.report(message{
+				severity: severityError,
+				err:      resourceWarning(res, erratt.Errorf("cannot normalize resource: %w", err)),
+			})
+			return
 		}
+		res = normalized
+	}
+	plain := res
+	if p.encrypter != nil {
+		encrypted, err := p.encrypter.EncryptResource(res)
+		if err != nil {
+			p.report(message{
+				severity: severityError,
+				err:      resourceWarning(res, erratt.Errorf("cannot encrypt resource: %w", err)),
+			})
+			return
+		}
+		res = encrypted
+	}
+	if err := p.out.Write(res, p.render); err != nil {
+		p.report(message{
+			severity: severityError,
+			err:      resourceWarning(res, err),
+		})
+		return
+	}
+	p.result.Resources++
+	p.events.resource(res)
+	if p.snapshot != nil {
+		p.snapshot.add(plain)
+	}
+}
 
-		slog.Info("Writing output to file", "output", o)
+// handleFailure keeps or discards the output of a failed run as
+// configured by the OnErrorParam.
+func (p *pipeline) handleFailure() {
+	switch p.out.(type) {
+	case consoleOutput, *httpOutput:
+		return
+	}
+	if OnErrorParam.Value() == onErrorKeep {
+		slog.Warn("Export failed, keeping the partial output", "resources", p.result.Resources)
+		out := p.out
+		if g, ok := out.(*gitOutput); ok {
+			// the partial output is left in the working tree
+			// without a commit
+			out = g.fileOutput
+		}
+		if err := out.Commit(); err != nil {
+			erratt.Slog(err)
+			return
+		}
+		p.committed = true
+		return
+	}
+	slog.Warn("Export failed, discarding the output", "resources", p.result.Resources)
+	if err := p.out.Abort(); err != nil {
+		erratt.Slog(err)
 	}
-	return fileOutput, nil
 }
 
-func resourceLoop(ctx context.Context, fileOutput *os.File, resourceChan <-chan resource.Object) {
+func resourceLoop(ctx context.Context, p *pipeline, resourceChan <-chan resource.Object) {
 	for {
 		select {
 		case res, ok := <-resourceChan:
@@ -55,25 +254,7 @@ func resourceLoop(ctx context.Context, fileOutput *os.File, resourceChan <-chan
 				// resource channel is closed
 				return
 			}
-			if fileOutput != nil {
-				// output to file
-				y, err := yaml.Marshal(res)
-				if err != nil {
-					erratt.Slog(erratt.Errorf("cannot YAML-marshal resource: %w", err).With("resource", res))
-				} else {
-					if _, err := fmt.Fprint(fileOutput, y); err != nil {
-						erratt.Slog(erratt.Errorf("cannot write YAML to output: %w", err).With("output", fileOutput.Name()))
-					}
-				}
-			} else {
-				// output to console
-				y, err := yaml.MarshalPretty(res)
-				if err != nil {
-					erratt.Slog(erratt.Errorf("cannot YAML-marshal resource: %w", err).With("resource", res))
-				} else {
-					fmt.Print(y)
-				}
-			}
+			p.process(res)
 		case <-ctx.Done():
 			// execution is cancelled
 			return
@@ -81,21 +262,9 @@ func resourceLoop(ctx context.Context, fileOutput *os.File, resourceChan <-chan
 	}
 }
 
-func handleResources(ctx context.Context, wg *sync.WaitGroup, resourceChan <-chan resource.Object, errChan chan<- error) {
+func handleResources(ctx context.Context, wg *sync.WaitGroup, p *pipeline, resourceChan <-chan resource.Object) {
 	defer wg.Done()
-	fileOutput, err := openOutput()
-	if err != nil {
-		errChan <- err
-	}
-	defer func() {
-		if fileOutput != nil {
-			err := fileOutput.Close()
-			if err != nil {
-				errChan <- erratt.Errorf("Cannot close output file: %w", err).With("output", fileOutput.Name())
-			}
-		}
-	}()
-	resourceLoop(ctx, fileOutput, resourceChan)
+	resourceLoop(ctx, p, resourceChan)
 }
 
 type handler[T any] struct {
@@ -130,34 +299,91 @@ func (h *handler[T]) Stop() {
 }
 
 type EventHandler interface {
+	// Info reports a notable but normal event, such as a skipped
+	// resource.
+	Info(error)
 	Warn(error)
+	// WarnResource reports a warning that concerns a resource. The
+	// warning is logged and added as a comment to the YAML document
+	// of the resource. If the warning is marked with [Blocking],
+	// the resource is commented out. WarnResource must be invoked
+	// before the resource is reported with Resource.
+	WarnResource(resource.Object, error)
+	// Error reports a non-fatal error, such as a resource that
+	// cannot be exported. The export continues, but the
+	// subcommand exits with [ExitCodePartialFailure].
+	Error(error)
 	Resource(resource.Object)
+	// Progress reports that done resources of kind are processed
+	// out of total. A total less than or equal to zero means that
+	// the total is unknown.
+	Progress(kind string, done, total int)
 	Stop()
 }
 
 type eventHandler struct {
-	errorHandler    *handler[error]
+	messageHandler  *handler[message]
 	resourceHandler *handler[resource.Object]
+	progressHandler *handler[progressEvent]
+	warnings        *warning.Tracker
+	// stopped is set when the export function invokes Stop.
+	stopped *atomic.Bool
 }
 
 var _ EventHandler = eventHandler{}
 
-func newEventHandler(ctx context.Context) eventHandler {
+func newEventHandler(ctx context.Context, p *pipeline) eventHandler {
 	return eventHandler{
-		errorHandler:    newHandler[error](ctx),
+		messageHandler:  newHandler[message](ctx),
 		resourceHandler: newHandler[resource.Object](ctx),
+		progressHandler: newHandler[progressEvent](ctx),
+		warnings:        p.warnings,
+		stopped:         &atomic.Bool{},
 	}
 }
 
+func (eh eventHandler) Info(err error) {
+	eh.messageHandler.Event(message{severity: severityInfo, err: err})
+}
+
 func (eh eventHandler) Warn(err error) {
-	eh.errorHandler.Event(err)
+	eh.messageHandler.Event(message{severity: severityWarning, err: err})
+}
+
+func (eh eventHandler) Error(err error) {
+	eh.messageHandler.Event(message{severity: severityError, err: err})
+}
+
+func (eh eventHandler) WarnResource(res resource.Object, err error) {
+	eh.warnings.Add(res, err)
+	eh.messageHandler.Event(message{severity: severityWarning, err: resourceWarning(res, err)})
 }
 
 func (eh eventHandler) Resource(res resource.Object) {
 	eh.resourceHandler.Event(res)
 }
 
+func (eh eventHandler) Progress(kind string, done, total int) {
+	eh.progressHandler.Event(progressEvent{
+		Kind:  kind,
+		Done:  done,
+		Total: total,
+	})
+}
+
+// Stop records that the export function has stopped the processing
+// and closes the handlers.
 func (eh eventHandler) Stop() {
-	eh.errorHandler.Stop()
+	if !eh.resourceHandler.closed {
+		eh.stopped.Store(true)
+	}
+	eh.close()
+}
+
+// close closes the handlers when the export function has returned,
+// whether or not it has invoked Stop.
+func (eh eventHandler) close() {
+	eh.messageHandler.Stop()
 	eh.resourceHandler.Stop()
+	eh.progressHandler.Stop()
 }
@@ -0,0 +1,64 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("A failed export", func() {
+	var dir, path string
+	var p *pipeline
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "out.yaml")
+		Expect(os.WriteFile(path, []byte("previous\n"), 0o600)).To(Succeed())
+		out := newFileOutput(path, false, 0, 0)
+		out.force = true
+		p = newTestPipeline(out)
+	})
+
+	failingExport := func(_ context.Context, events EventHandler) error {
+		events.Resource(newResource("Bucket", "logs"))
+		events.Resource(newResource("Bucket", "backups"))
+		return errors.New("connection lost")
+	}
+
+	It("discards the partial output by default", func() {
+		Expect(runExport(context.Background(), p, failingExport)).To(MatchError("connection lost"))
+		Expect(outputFiles(dir)).To(ConsistOf("out.yaml"))
+		Expect(os.ReadFile(path)).To(BeEquivalentTo("previous\n"))
+	})
+
+	It("keeps the partial output on request", func() {
+		setParam(OnErrorParam, onErrorKeep)
+		Expect(runExport(context.Background(), p, failingExport)).To(MatchError("connection lost"))
+		Expect(outputFiles(dir)).To(ConsistOf("out.yaml"))
+		Expect(documents(path)).To(Equal(2))
+		Expect(p.committed).To(BeTrue())
+	})
+
+	It("discards the partial output when it is interrupted", func() {
+		setParam(OnErrorParam, onErrorDiscard)
+		ctx, cancel := context.WithCancel(context.Background())
+		err := runExport(ctx, p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newResource("Bucket", "logs"))
+			cancel()
+			return nil
+		})
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(os.ReadFile(path)).To(BeEquivalentTo("previous\n"))
+	})
+})
+
+var _ = Describe("The on-error parameter", func() {
+	It("rejects unknown values", func() {
+		setParam(OnErrorParam, "retry")
+		_, err := openOutput(nil)
+		Expect(err).To(MatchError("Invalid on-error value"))
+	})
+})
@@ -0,0 +1,271 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+const eventsFormatJSON = "json"
+
+// eventType identifies the kind of an event in the event stream.
+type eventType string
+
+const (
+	eventRunStarted   eventType = "runStarted"
+	eventKindStarted  eventType = "kindStarted"
+	eventResource     eventType = "resource"
+	eventInfo         eventType = "info"
+	eventWarning      eventType = "warning"
+	eventError        eventType = "error"
+	eventProgress     eventType = "progress"
+	eventKindFinished eventType = "kindFinished"
+	eventStop         eventType = "stop"
+	eventCancelled    eventType = "cancelled"
+	eventChanges      eventType = "changes"
+	eventRunFinished  eventType = "runFinished"
+)
+
+// event is a single line of the NDJSON event stream.
+type event struct {
+	Time       time.Time      `json:"time"`
+	Type       eventType      `json:"type"`
+	APIVersion string         `json:"apiVersion,omitempty"`
+	Kind       string         `json:"kind,omitempty"`
+	Name       string         `json:"name,omitempty"`
+	Namespace  string         `json:"namespace,omitempty"`
+	Commented  bool           `json:"commented,omitempty"`
+	Count      *int           `json:"count,omitempty"`
+	Done       *int           `json:"done,omitempty"`
+	Total      *int           `json:"total,omitempty"`
+	Message    string         `json:"message,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+// eventLog writes the events of an export run as newline-delimited
+// JSON. An eventLog without a writer only keeps track of the
+// exported kinds.
+type eventLog struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+	kinds  []string
+	counts map[string]int
+}
+
+func newEventLog(w io.Writer) *eventLog {
+	l := &eventLog{
+		counts: map[string]int{},
+	}
+	if w != nil {
+		l.enc = json.NewEncoder(w)
+	}
+	if c, ok := w.(io.Closer); ok && w != os.Stderr {
+		l.closer = c
+	}
+	return l
+}
+
+// openEventLog creates the eventLog configured by the EventsFileParam
+// and the EventsFormatParam.
+func openEventLog() (*eventLog, erratt.Error) {
+	file := EventsFileParam.Value()
+	switch format := EventsFormatParam.Value(); {
+	case format != "" && format != eventsFormatJSON:
+		return nil, erratt.New("Invalid events-format value", "events-format", format, "supported", eventsFormatJSON)
+	case file != "":
+		f, err := os.Create(filepath.Clean(file))
+		if err != nil {
+			return nil, erratt.Errorf("Cannot create events file: %w", err).With("events-file", file)
+		}
+		return newEventLog(f), nil
+	case format == eventsFormatJSON:
+		return newEventLog(os.Stderr), nil
+	}
+	return newEventLog(nil), nil
+}
+
+func (l *eventLog) emit(ev event) {
+	if l.enc == nil {
+		return
+	}
+	ev.Time = time.Now()
+	// The encoding errors are ignored, the event stream must not
+	// break the export.
+	_ = l.enc.Encode(ev)
+}
+
+// runStarted records the start of a run. The kinds exported by the
+// previous run of the watch mode are forgotten.
+func (l *eventLog) runStarted() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.kinds = nil
+	l.counts = map[string]int{}
+	l.emit(event{Type: eventRunStarted})
+}
+
+// resource records that res is written to the output. The first
+// resource of a kind is preceded by a kindStarted event.
+func (l *eventLog) resource(res resource.Object) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	gvk := res.GetObjectKind().GroupVersionKind()
+	if _, ok := l.counts[gvk.Kind]; !ok {
+		l.kinds = append(l.kinds, gvk.Kind)
+		l.emit(event{Type: eventKindStarted, Kind: gvk.Kind})
+	}
+	l.counts[gvk.Kind]++
+	commented := false
+	if c, ok := res.(yaml.CommentedYAML); ok {
+		_, commented = c.Comment()
+	}
+	l.emit(event{
+		Type:       eventResource,
+		APIVersion: gvk.GroupVersion().String(),
+		Kind:       gvk.Kind,
+		Name:       res.GetName(),
+		Namespace:  res.GetNamespace(),
+		Commented:  commented,
+	})
+}
+
+func (l *eventLog) message(msg message) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	t := eventWarning
+	switch msg.severity {
+	case severityInfo:
+		t = eventInfo
+	case severityError:
+		t = eventError
+	}
+	l.emit(event{
+		Type:       t,
+		Message:    msg.err.Error(),
+		Attributes: errorAttributes(msg.err),
+	})
+}
+
+func (l *eventLog) progress(ev progressEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	progress := event{
+		Type: eventProgress,
+		Kind: ev.Kind,
+		Done: &ev.Done,
+	}
+	if ev.Total > 0 {
+		progress.Total = &ev.Total
+	}
+	l.emit(progress)
+}
+
+func (l *eventLog) stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.emit(event{Type: eventStop})
+}
+
+func (l *eventLog) cancelled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.emit(event{Type: eventCancelled})
+}
+
+// changes records the numbers of the resources that have changed
+// since the previous run of the watch mode.
+func (l *eventLog) changes(d snapshotDiff) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ev := event{
+		Type:       eventChanges,
+		Attributes: map[string]any{},
+	}
+	attrs := d.attrs()
+	for i := 0; i+1 < len(attrs); i += 2 {
+		ev.Attributes[attrs[i].(string)] = attrs[i+1]
+	}
+	l.emit(ev)
+}
+
+// runFinished records a kindFinished event with the number of
+// exported resources for each exported kind, followed by the
+// runFinished event with the counters of result. The error of a
+// failed run is recorded as the message of the runFinished event.
+func (l *eventLog) runFinished(result *runResult, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, kind := range l.kinds {
+		count := l.counts[kind]
+		l.emit(event{Type: eventKindFinished, Kind: kind, Count: &count})
+	}
+	ev := event{
+		Type:       eventRunFinished,
+		Attributes: map[string]any{},
+	}
+	if err != nil {
+		ev.Message = err.Error()
+		ev.Attributes = errorAttributes(err)
+		if ev.Attributes == nil {
+			ev.Attributes = map[string]any{}
+		}
+	}
+	attrs := result.attrs()
+	for i := 0; i+1 < len(attrs); i += 2 {
+		ev.Attributes[attrs[i].(string)] = attrs[i+1]
+	}
+	l.emit(ev)
+}
+
+// kindCounts returns the number of resources per kind written in the
+// current run.
+func (l *eventLog) kindCounts() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return maps.Clone(l.counts)
+}
+
+// Close closes the events file.
+func (l *eventLog) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+	return l.closer.Close()
+}
+
+// errorAttributes returns the attributes of an [erratt.Error] as a
+// map. Values that are not strings, numbers or bools are formatted
+// with fmt.Sprint.
+func errorAttributes(err error) map[string]any {
+	var attrErr interface{ Attrs() []any }
+	if !errors.As(err, &attrErr) {
+		return nil
+	}
+	attrs := attrErr.Attrs()
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(attrs)/2)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		key := fmt.Sprint(attrs[i])
+		switch v := attrs[i+1].(type) {
+		case string, bool, int, int32, int64, uint, uint32, uint64, float32, float64, nil:
+			m[key] = v
+		default:
+			m[key] = fmt.Sprint(v)
+		}
+	}
+	return m
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/erratt"
+)
+
+// readEvents parses the NDJSON event stream in buf.
+func readEvents(buf *bytes.Buffer) []event {
+	events := []event{}
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		ev := event{}
+		ExpectWithOffset(1, json.Unmarshal(scanner.Bytes(), &ev)).To(Succeed())
+		events = append(events, ev)
+	}
+	return events
+}
+
+// eventTypes returns the types of events.
+func eventTypes(events []event) []eventType {
+	types := make([]eventType, 0, len(events))
+	for _, ev := range events {
+		types = append(types, ev.Type)
+	}
+	return types
+}
+
+var _ = Describe("The event stream", func() {
+	var buf *bytes.Buffer
+	var p *pipeline
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		p = newTestPipeline(&recordingOutput{})
+		p.events = newEventLog(buf)
+	})
+
+	It("records the events of a run", func() {
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newResource("Bucket", "logs"))
+			events.Warn(erratt.New("slow API", "endpoint", "/buckets"))
+			events.Resource(newResource("Bucket", "backups"))
+			events.Stop()
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		events := readEvents(buf)
+		Expect(eventTypes(events)).To(HaveLen(8))
+		Expect(events[0].Type).To(Equal(eventRunStarted))
+		// the messages and the resources are processed
+		// concurrently, so their events may interleave
+		Expect(eventTypes(events[1:5])).To(ConsistOf(eventKindStarted, eventResource, eventWarning, eventResource))
+		Expect(eventTypes(events[5:])).To(Equal([]eventType{eventStop, eventKindFinished, eventRunFinished}))
+		Expect(events[1:5]).To(ContainElement(SatisfyAll(
+			HaveField("Type", eventResource),
+			HaveField("APIVersion", "test.example.com/v1"),
+			HaveField("Kind", "Bucket"),
+			HaveField("Name", "logs"),
+		)))
+		Expect(events[1:5]).To(ContainElement(SatisfyAll(
+			HaveField("Type", eventWarning),
+			HaveField("Message", "slow API"),
+			HaveField("Attributes", map[string]any{"endpoint": "/buckets"}),
+		)))
+		Expect(*events[6].Count).To(Equal(2))
+		Expect(events[7].Attributes).To(HaveKeyWithValue("resources", 2.0))
+		Expect(events[7].Attributes).To(HaveKeyWithValue("warnings", 1.0))
+	})
+
+	It("records a stop event only if the export function stops", func() {
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newResource("Bucket", "logs"))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(eventTypes(readEvents(buf))).NotTo(ContainElement(eventStop))
+	})
+
+	It("records the progress", func() {
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Progress("Bucket", 1, 0)
+			events.Progress("Bucket", 2, 2)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		events := readEvents(buf)
+		Expect(eventTypes(events)).To(Equal([]eventType{eventRunStarted, eventProgress, eventProgress, eventRunFinished}))
+		Expect(events[1].Total).To(BeNil())
+		Expect(*events[2].Done).To(Equal(2))
+		Expect(*events[2].Total).To(Equal(2))
+	})
+
+	It("records the error of a failed run", func() {
+		err := runExport(context.Background(), p, func(context.Context, EventHandler) error {
+			return erratt.New("cannot log in", "user", "admin")
+		})
+		Expect(err).To(MatchError("cannot log in"))
+		events := readEvents(buf)
+		finished := events[len(events)-1]
+		Expect(finished.Type).To(Equal(eventRunFinished))
+		Expect(finished.Message).To(Equal("cannot log in"))
+		Expect(finished.Attributes).To(HaveKeyWithValue("user", "admin"))
+	})
+
+	DescribeTable("records the cancellation of a run",
+		func(result func(ctx context.Context) error) {
+			ctx, cancel := context.WithCancel(context.Background())
+			err := runExport(ctx, p, func(ctx context.Context, _ EventHandler) error {
+				cancel()
+				return result(ctx)
+			})
+			Expect(err).To(MatchError(ContainSubstring("export is interrupted")))
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			Expect(eventTypes(readEvents(buf))).To(ContainElement(eventCancelled))
+		},
+		Entry("when the export function returns the error of the context", func(ctx context.Context) error {
+			return ctx.Err()
+		}),
+		Entry("when the export function returns without an error", func(context.Context) error {
+			return nil
+		}),
+	)
+})
+
+var _ = Describe("openEventLog", func() {
+	It("rejects an unknown format", func() {
+		setParam(EventsFormatParam, "xml")
+		_, err := openEventLog()
+		Expect(err).To(MatchError(ContainSubstring("Invalid events-format value")))
+	})
+	It("writes the events into the events file", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "events.ndjson")
+		setParam(EventsFileParam, path)
+		l, err := openEventLog()
+		Expect(err).NotTo(HaveOccurred())
+		l.runStarted()
+		Expect(l.Close()).To(Succeed())
+		b, readErr := os.ReadFile(path)
+		Expect(readErr).NotTo(HaveOccurred())
+		Expect(eventTypes(readEvents(bytes.NewBuffer(b)))).To(Equal([]eventType{eventRunStarted}))
+	})
+	It("records no events by default", func() {
+		l, err := openEventLog()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(l.enc).To(BeNil())
+	})
+})
+
+var _ = Describe("errorAttributes", func() {
+	It("keeps the JSON values and formats the others", func() {
+		err := erratt.New("failed", "count", 2, "names", []string{"a", "b"})
+		Expect(errorAttributes(err)).To(Equal(map[string]any{"count": 2, "names": "[a b]"}))
+	})
+	It("returns nil for errors without attributes", func() {
+		Expect(errorAttributes(errors.New("failed"))).To(BeNil())
+	})
+})
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/cli/configparam"
+	"github.com/SAP/xp-clifford/cli/export/internal/warning"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestExport(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Export Suite")
+}
+
+// The config parameters are global, so they are reset after each
+// spec.
+var _ = AfterEach(func() {
+	viper.Reset()
+})
+
+// setParam sets the value of param for the current spec.
+func setParam(param configparam.ConfigParam, value any) {
+	viper.Set(param.GetName(), value)
+}
+
+// newResource returns an unstructured resource of the given kind.
+func newResource(kind, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.example.com/v1",
+		"kind":       kind,
+		"metadata":   map[string]any{"name": name},
+	}}
+}
+
+// newTestPipeline returns a pipeline without optional stages that
+// writes YAML documents into out.
+func newTestPipeline(out output) *pipeline {
+	return &pipeline{
+		filter:   acceptAll,
+		render:   yamlRenderer(),
+		out:      out,
+		events:   newEventLog(nil),
+		warnings: warning.NewTracker(),
+		logger:   slog.New(slog.DiscardHandler),
+	}
+}
+
+// recordingOutput keeps the written resources in memory.
+type recordingOutput struct {
+	resources []resource.Object
+}
+
+var _ output = &recordingOutput{}
+
+func (o *recordingOutput) Write(res resource.Object, _ renderFunc) error {
+	o.resources = append(o.resources, res)
+	return nil
+}
+
+func (o *recordingOutput) Commit() error {
+	return nil
+}
+
+func (o *recordingOutput) Abort() error {
+	return nil
+}
+
+// runExport runs the export function fn once with p.
+func runExport(ctx context.Context, p *pipeline, fn func(context.Context, EventHandler) error) error {
+	c := &exportSubCommand{runCommand: fn}
+	return c.run(ctx, p, nil)
+}
+
+// newTextLogger returns a logger writing text lines without time to
+// buf.
+func newTextLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// logLines returns the lines logged into buf.
+func logLines(buf *bytes.Buffer) []string {
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
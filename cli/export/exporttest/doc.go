@@ -0,0 +1,31 @@
+/*
+Package exporttest runs export functions in tests, without building
+and running the exporter binary.
+
+An export function, as set with [export.SetCommand], is invoked with
+[Run]. The [Result] holds the resources, messages and progress that
+the function reports to its [export.EventHandler]:
+
+	func TestExport(t *testing.T) {
+		result := exporttest.Run(context.Background(), exportLogic)
+		result.AssertNoErrors(t)
+		result.AssertCount(t, "Bucket", 2)
+		result.AssertGolden(t, "testdata/export.yaml")
+	}
+
+The warnings reported for a resource with WarnResource are added to
+the resource as comments, like the export subcommand writes them.
+[Result.AssertGolden] compares the resources, rendered like the
+export subcommand writes them, with a golden file, usually in the
+testdata directory of the package. The timestamps are replaced with a
+placeholder, and other non-deterministic values can be removed or
+replaced with [GoldenOption] values. The golden files are regenerated
+by running the tests with the -update-golden flag.
+
+The assertions accept any [testing.TB], including GinkgoT() in
+Ginkgo suites.
+
+A [Recorder] can be passed to export functions directly, when the
+function is invoked by other code under test.
+*/
+package exporttest
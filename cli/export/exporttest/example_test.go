@@ -0,0 +1,64 @@
+package exporttest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SAP/xp-clifford/cli/export"
+	"github.com/SAP/xp-clifford/cli/export/exporttest"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newBucket(name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.example.com/v1",
+		"kind":       "Bucket",
+		"metadata":   map[string]any{"name": name},
+	}}
+}
+
+func exportLogic(_ context.Context, events export.EventHandler) error {
+	events.Resource(newBucket("logs"))
+	locked := newBucket("locked")
+	events.WarnResource(locked, export.Blocking(errors.New("bucket is locked")))
+	events.Resource(locked)
+	events.Stop()
+	return nil
+}
+
+func ExampleRun() {
+	result := exporttest.Run(context.Background(), exportLogic)
+	fmt.Println(result.Count("Bucket"), len(result.Warnings), result.Stopped)
+	comment, commentedOut := exporttest.Comment(result.Find("Bucket", "locked"))
+	fmt.Print(commentedOut, " ", comment)
+	//output:
+	// 2 1 true
+	// true BLOCKING WARNING: bucket is locked
+}
+
+func ExampleResult_YAML() {
+	result := exporttest.Run(context.Background(), exportLogic)
+	y, err := result.YAML()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Print(y)
+	//output:
+	// ---
+	// apiVersion: test.example.com/v1
+	// kind: Bucket
+	// metadata:
+	//   name: logs
+	// ...
+	// #
+	// # BLOCKING WARNING: bucket is locked
+	// #
+	// # ---
+	// # apiVersion: test.example.com/v1
+	// # kind: Bucket
+	// # metadata:
+	// #   name: locked
+	// # ...
+}
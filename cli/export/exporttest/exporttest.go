@@ -0,0 +1,220 @@
+package exporttest
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SAP/xp-clifford/cli/export"
+	"github.com/SAP/xp-clifford/cli/export/internal/warning"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// Progress is the latest progress reported for a kind. A total less
+// than or equal to zero means that the total is unknown.
+type Progress struct {
+	Done  int
+	Total int
+}
+
+// Result holds the events reported by an export function.
+type Result struct {
+	// Resources are the reported resources in the order of their
+	// reports. The warnings reported for a resource are added as
+	// comments.
+	Resources []resource.Object
+	// Infos, Warnings and Errors are the reported messages. The
+	// Warnings include the warnings reported for resources.
+	Infos    []error
+	Warnings []error
+	Errors   []error
+	// Progress is the latest progress reported per kind.
+	Progress map[string]Progress
+	// Stopped is set if the export function has invoked Stop.
+	Stopped bool
+	// Err is the error returned by the export function.
+	Err error
+}
+
+// Recorder is an [export.EventHandler] that records the reported
+// events in memory. Like the handler of the export subcommand, it
+// ignores the events reported after Stop. It is safe for concurrent
+// use.
+type Recorder struct {
+	mu       sync.Mutex
+	result   Result
+	warnings *warning.Tracker
+}
+
+var _ export.EventHandler = &Recorder{}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		result:   Result{Progress: map[string]Progress{}},
+		warnings: warning.NewTracker(),
+	}
+}
+
+// Run invokes the export function fn with a Recorder and returns the
+// recorded result.
+func Run(ctx context.Context, fn func(context.Context, export.EventHandler) error) *Result {
+	rec := NewRecorder()
+	err := fn(ctx, rec)
+	result := rec.Result()
+	result.Err = err
+	return result
+}
+
+// Result returns a copy of the events recorded so far.
+func (r *Recorder) Result() *Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := r.result
+	result.Resources = slices.Clone(r.result.Resources)
+	result.Infos = slices.Clone(r.result.Infos)
+	result.Warnings = slices.Clone(r.result.Warnings)
+	result.Errors = slices.Clone(r.result.Errors)
+	result.Progress = maps.Clone(r.result.Progress)
+	return &result
+}
+
+// record invokes fn with the lock held, unless Stop has been invoked.
+func (r *Recorder) record(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.result.Stopped {
+		fn()
+	}
+}
+
+func (r *Recorder) Info(err error) {
+	r.record(func() { r.result.Infos = append(r.result.Infos, err) })
+}
+
+func (r *Recorder) Warn(err error) {
+	r.record(func() { r.result.Warnings = append(r.result.Warnings, err) })
+}
+
+func (r *Recorder) Error(err error) {
+	r.record(func() { r.result.Errors = append(r.result.Errors, err) })
+}
+
+// WarnResource records the warning, which is added as a comment to
+// res when res is reported with Resource.
+func (r *Recorder) WarnResource(res resource.Object, err error) {
+	r.record(func() {
+		r.result.Warnings = append(r.result.Warnings, err)
+		r.warnings.Add(res, err)
+	})
+}
+
+func (r *Recorder) Resource(res resource.Object) {
+	r.record(func() {
+		res = r.warnings.Annotate(res)
+		r.result.Resources = append(r.result.Resources, res)
+	})
+}
+
+func (r *Recorder) Progress(kind string, done, total int) {
+	r.record(func() { r.result.Progress[kind] = Progress{Done: done, Total: total} })
+}
+
+func (r *Recorder) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.result.Stopped = true
+}
+
+// Find returns the first resource of the given kind and name, or nil.
+func (r *Result) Find(kind, name string) resource.Object {
+	for _, res := range r.Resources {
+		if res.GetObjectKind().GroupVersionKind().Kind == kind && res.GetName() == name {
+			return res
+		}
+	}
+	return nil
+}
+
+// Count returns the number of resources of the given kind.
+func (r *Result) Count(kind string) int {
+	n := 0
+	for _, res := range r.Resources {
+		if res.GetObjectKind().GroupVersionKind().Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// Comment returns the comment of res and reports whether res is
+// commented out.
+func Comment(res resource.Object) (string, bool) {
+	if c, ok := res.(yaml.CommentedYAML); ok {
+		return c.Comment()
+	}
+	return "", false
+}
+
+// YAML returns the resources as a YAML stream, in the format in which
+// the export subcommand writes them into the output file.
+func (r *Result) YAML(opts ...yaml.Option) (string, error) {
+	sb := &strings.Builder{}
+	for _, res := range r.Resources {
+		y, err := yaml.Marshal(res, opts...)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(y)
+	}
+	return sb.String(), nil
+}
+
+// AssertNoErrors fails t if the export function has returned or
+// reported an error.
+func (r *Result) AssertNoErrors(t testing.TB) {
+	t.Helper()
+	if r.Err != nil {
+		t.Errorf("export function failed: %v", r.Err)
+	}
+	for _, err := range r.Errors {
+		t.Errorf("export function reported an error: %v", err)
+	}
+}
+
+// AssertCount fails t if the number of resources of the given kind
+// is not n.
+func (r *Result) AssertCount(t testing.TB, kind string, n int) {
+	t.Helper()
+	if got := r.Count(kind); got != n {
+		t.Errorf("expected %d resources of kind %s, got %d", n, kind, got)
+	}
+}
+
+// AssertResource fails t if no resource of the given kind and name
+// has been reported, and returns the resource otherwise.
+func (r *Result) AssertResource(t testing.TB, kind, name string) resource.Object {
+	t.Helper()
+	res := r.Find(kind, name)
+	if res == nil {
+		t.Errorf("expected resource %s %q, not reported", kind, name)
+	}
+	return res
+}
+
+// AssertWarning fails t if no warning containing text has been
+// reported.
+func (r *Result) AssertWarning(t testing.TB, text string) {
+	t.Helper()
+	for _, err := range r.Warnings {
+		if strings.Contains(err.Error(), text) {
+			return
+		}
+	}
+	t.Errorf("expected a warning containing %q, got %d warnings", text, len(r.Warnings))
+}
@@ -0,0 +1,13 @@
+package exporttest_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestExporttest(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Exporttest Suite")
+}
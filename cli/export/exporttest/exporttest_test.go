@@ -0,0 +1,101 @@
+package exporttest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/cli/export"
+	"github.com/SAP/xp-clifford/cli/export/exporttest"
+)
+
+// errFatal stops an assertion that fails a fakeT with Fatalf.
+var errFatal = errors.New("fatal failure")
+
+// fakeT records the failures of the assertions.
+type fakeT struct {
+	testing.TB
+	failures []string
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...any) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Logf(string, ...any) {}
+
+func (t *fakeT) Fatalf(format string, args ...any) {
+	t.Errorf(format, args...)
+	panic(errFatal)
+}
+
+// run invokes the assertion fn, which may stop with Fatalf.
+func (t *fakeT) run(fn func()) {
+	defer func() {
+		if r := recover(); r != nil && r != errFatal {
+			panic(r)
+		}
+	}()
+	fn()
+}
+
+var _ = Describe("Run", func() {
+	It("records the messages and the progress", func() {
+		result := exporttest.Run(context.Background(), func(_ context.Context, events export.EventHandler) error {
+			events.Info(errors.New("skipped"))
+			events.Warn(errors.New("slow API"))
+			events.Error(errors.New("cannot export"))
+			events.Progress("Bucket", 1, 2)
+			events.Progress("Bucket", 2, 2)
+			return errors.New("failed")
+		})
+		Expect(result.Infos).To(HaveLen(1))
+		Expect(result.Warnings).To(HaveLen(1))
+		Expect(result.Errors).To(HaveLen(1))
+		Expect(result.Progress).To(Equal(map[string]exporttest.Progress{"Bucket": {Done: 2, Total: 2}}))
+		Expect(result.Stopped).To(BeFalse())
+		Expect(result.Err).To(MatchError("failed"))
+	})
+
+	It("ignores the events reported after Stop", func() {
+		result := exporttest.Run(context.Background(), func(_ context.Context, events export.EventHandler) error {
+			events.Stop()
+			events.Resource(newBucket("late"))
+			events.Warn(errors.New("late"))
+			return nil
+		})
+		Expect(result.Resources).To(BeEmpty())
+		Expect(result.Warnings).To(BeEmpty())
+	})
+})
+
+var _ = Describe("Assertions", func() {
+	var t *fakeT
+	var result *exporttest.Result
+
+	BeforeEach(func() {
+		t = &fakeT{}
+		result = exporttest.Run(context.Background(), exportLogic)
+	})
+
+	It("pass for a matching result", func() {
+		result.AssertNoErrors(t)
+		result.AssertCount(t, "Bucket", 2)
+		Expect(result.AssertResource(t, "Bucket", "logs")).NotTo(BeNil())
+		result.AssertWarning(t, "locked")
+		Expect(t.failures).To(BeEmpty())
+	})
+
+	It("fail for a differing result", func() {
+		result.AssertCount(t, "Bucket", 3)
+		Expect(result.AssertResource(t, "User", "admin")).To(BeNil())
+		result.AssertWarning(t, "quota")
+		Expect(t.failures).To(HaveLen(3))
+	})
+})
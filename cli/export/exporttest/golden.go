@@ -0,0 +1,157 @@
+package exporttest
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// timestampPlaceholder replaces the timestamps in the golden files.
+const timestampPlaceholder = "<timestamp>"
+
+// update is set by the -update-golden flag of go test.
+var update = flag.Bool("update-golden", false, "regenerate the golden files of the exporttest assertions")
+
+// timestampPattern matches RFC 3339 timestamps, like the creation
+// timestamps of resources.
+var timestampPattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+// replacement replaces the matches of a pattern in the rendered
+// output.
+type replacement struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+// golden is the configuration of the rendering of a golden file.
+type golden struct {
+	yamlOptions  []yaml.Option
+	normalizer   *yaml.Normalizer
+	replacements []replacement
+}
+
+// GoldenOption configures the rendering of a golden file.
+type GoldenOption func(*golden)
+
+// WithYAMLOptions renders the resources with the given options, like
+// the export subcommand configured with the corresponding flags.
+func WithYAMLOptions(opts ...yaml.Option) GoldenOption {
+	return func(g *golden) {
+		g.yamlOptions = append(g.yamlOptions, opts...)
+	}
+}
+
+// WithNormalizer normalizes the resources with n before they are
+// rendered, for example to remove non-deterministic fields like
+// "metadata.uid".
+func WithNormalizer(n *yaml.Normalizer) GoldenOption {
+	return func(g *golden) {
+		g.normalizer = n
+	}
+}
+
+// WithReplacement replaces the matches of pattern in the rendered
+// output with placeholder, in addition to the timestamps.
+func WithReplacement(pattern *regexp.Regexp, placeholder string) GoldenOption {
+	return func(g *golden) {
+		g.replacements = append(g.replacements, replacement{pattern, placeholder})
+	}
+}
+
+// Golden returns the YAML stream of the resources, as written by the
+// export subcommand, with the non-deterministic values replaced. The
+// RFC 3339 timestamps are replaced with "<timestamp>".
+func (r *Result) Golden(opts ...GoldenOption) (string, error) {
+	g := &golden{
+		replacements: []replacement{{timestampPattern, timestampPlaceholder}},
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	resources := r.Resources
+	if g.normalizer != nil {
+		resources = make([]resource.Object, 0, len(r.Resources))
+		for _, res := range r.Resources {
+			normalized, err := g.normalizer.NormalizeResource(res)
+			if err != nil {
+				return "", fmt.Errorf("cannot normalize resource %q: %w", res.GetName(), err)
+			}
+			resources = append(resources, normalized)
+		}
+	}
+	y, err := (&Result{Resources: resources}).YAML(g.yamlOptions...)
+	if err != nil {
+		return "", err
+	}
+	for _, rep := range g.replacements {
+		y = rep.pattern.ReplaceAllLiteralString(y, rep.placeholder)
+	}
+	return y, nil
+}
+
+// AssertGolden fails t if the output rendered by [Result.Golden]
+// differs from the content of the golden file at path, like
+// "testdata/export.yaml". With the -update-golden flag of go test,
+// the golden file is written instead:
+//
+//	go test ./... -update-golden
+func (r *Result) AssertGolden(t testing.TB, path string, opts ...GoldenOption) {
+	t.Helper()
+	got, err := r.Golden(opts...)
+	if err != nil {
+		t.Fatalf("cannot render the resources: %v", err)
+	}
+	path = filepath.Clean(path)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+			t.Fatalf("cannot create golden file directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o600); err != nil {
+			t.Fatalf("cannot write golden file: %v", err)
+		}
+		t.Logf("golden file %s is updated", path)
+		return
+	}
+	want, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("golden file %s does not exist, run go test with -update-golden to create it", path)
+	}
+	if err != nil {
+		t.Fatalf("cannot read golden file: %v", err)
+	}
+	if diff := firstDifference(string(want), got); diff != "" {
+		t.Errorf("output differs from golden file %s: %s", path, diff)
+	}
+}
+
+// firstDifference describes the first line in which got differs from
+// want, or returns an empty string if they are equal.
+func firstDifference(want, got string) string {
+	if want == got {
+		return ""
+	}
+	wantLines := strings.Split(strings.TrimSuffix(want, "\n"), "\n")
+	gotLines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	for i := 0; ; i++ {
+		switch {
+		case i >= len(wantLines) && i >= len(gotLines):
+			return "the final newline differs"
+		case i >= len(wantLines):
+			return fmt.Sprintf("unexpected line %d: %q", i+1, gotLines[i])
+		case i >= len(gotLines):
+			return fmt.Sprintf("missing line %d: %q", i+1, wantLines[i])
+		case wantLines[i] != gotLines[i]:
+			return fmt.Sprintf("line %d: expected %q, got %q", i+1, wantLines[i], gotLines[i])
+		}
+	}
+}
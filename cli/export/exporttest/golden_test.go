@@ -0,0 +1,96 @@
+package exporttest_test
+
+import (
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/cli/export"
+	"github.com/SAP/xp-clifford/cli/export/exporttest"
+	"github.com/SAP/xp-clifford/yaml"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// exportWithTimestamps reports resources with non-deterministic
+// fields.
+func exportWithTimestamps(_ context.Context, events export.EventHandler) error {
+	for _, name := range []string{"logs", "backups"} {
+		res := newBucket(name)
+		res.SetCreationTimestamp(metav1.Now())
+		res.SetUID(types.UID("2b5c7d2e-" + name))
+		res.Object["spec"] = map[string]any{"owner": "team-" + time.Now().Format("150405.000")}
+		events.Resource(res)
+	}
+	events.Stop()
+	return nil
+}
+
+var _ = Describe("Golden files", func() {
+	var t *fakeT
+	var result *exporttest.Result
+	var opts []exporttest.GoldenOption
+
+	BeforeEach(func() {
+		t = &fakeT{}
+		result = exporttest.Run(context.Background(), exportWithTimestamps)
+		opts = []exporttest.GoldenOption{
+			exporttest.WithNormalizer(yaml.NewNormalizer().WithRemovedPaths("metadata.uid")),
+			exporttest.WithReplacement(regexp.MustCompile(`team-[0-9.]+`), "team-<time>"),
+			exporttest.WithYAMLOptions(yaml.WithCanonicalOrder()),
+		}
+	})
+
+	It("renders the resources with the non-deterministic values replaced", func() {
+		y, err := result.Golden(opts...)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(y).To(ContainSubstring(`creationTimestamp: "<timestamp>"`))
+		Expect(y).To(ContainSubstring("owner: team-<time>"))
+		Expect(y).NotTo(ContainSubstring("uid:"))
+		By("leaving the resources untouched")
+		Expect(result.Resources[0].GetUID()).NotTo(BeEmpty())
+	})
+
+	It("matches the golden file", func() {
+		result.AssertGolden(t, "testdata/buckets.yaml", opts...)
+		Expect(t.failures).To(BeEmpty())
+	})
+
+	It("reports the first difference", func() {
+		result.Resources = result.Resources[:1]
+		result.AssertGolden(t, "testdata/buckets.yaml", opts...)
+		Expect(t.failures).To(ConsistOf(HavePrefix("output differs from golden file testdata/buckets.yaml: missing line")))
+	})
+
+	It("reports a missing golden file", func() {
+		t.run(func() { result.AssertGolden(t, "testdata/missing.yaml") })
+		Expect(t.failures).To(ConsistOf(ContainSubstring("-update-golden")))
+	})
+
+	It("writes the golden file with the update flag", func() {
+		dir := GinkgoT().TempDir()
+		wd, err := os.Getwd()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.Chdir(dir)).To(Succeed())
+		Expect(flag.Set("update-golden", "true")).To(Succeed())
+		DeferCleanup(func() {
+			Expect(flag.Set("update-golden", "false")).To(Succeed())
+			Expect(os.Chdir(wd)).To(Succeed())
+		})
+
+		result.AssertGolden(t, "testdata/buckets.yaml", opts...)
+		Expect(t.failures).To(BeEmpty())
+		written, err := os.ReadFile(filepath.Join(dir, "testdata", "buckets.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		golden, err := os.ReadFile(filepath.Join(wd, "testdata", "buckets.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(written)).To(Equal(string(golden)))
+	})
+})
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"github.com/SAP/xp-clifford/erratt"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/google/cel-go/cel"
+)
+
+// filterFunc reports whether a resource shall be written to the
+// output.
+type filterFunc func(res resource.Object) (bool, erratt.Error)
+
+// acceptAll is the filterFunc used when no filter is configured.
+func acceptAll(resource.Object) (bool, erratt.Error) {
+	return true, nil
+}
+
+// celFilter compiles the CEL expression into a filterFunc. The
+// expression can refer to the content of the resource through the
+// 'object' variable and must evaluate to a bool. Expressions that
+// cannot evaluate to a bool are rejected at compile time, the others
+// are checked for each resource.
+func celFilter(expression string) (filterFunc, erratt.Error) {
+	env, err := cel.NewEnv(cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, erratt.Errorf("Cannot create CEL environment: %w", err)
+	}
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, erratt.Errorf("Cannot compile filter expression: %w", issues.Err()).With("filter", expression)
+	}
+	if t := ast.OutputType(); !t.IsExactType(cel.BoolType) && !t.IsExactType(cel.DynType) {
+		return nil, erratt.New("Filter expression must evaluate to bool", "filter", expression, "type", ast.OutputType().String())
+	}
+	prg, err := env.Program(ast)
+	if err != nil {
+		return nil, erratt.Errorf("Cannot create filter program: %w", err).With("filter", expression)
+	}
+	return func(res resource.Object) (bool, erratt.Error) {
+		content, err := objectContent(res)
+		if err != nil {
+			return false, erratt.Errorf("cannot convert resource: %w", err)
+		}
+		val, _, err := prg.Eval(map[string]any{
+			"object": content,
+		})
+		if err != nil {
+			return false, erratt.Errorf("cannot evaluate filter expression: %w", err).With("filter", expression)
+		}
+		accepted, ok := val.Value().(bool)
+		if !ok {
+			return false, erratt.New("Filter expression did not evaluate to bool", "filter", expression)
+		}
+		return accepted, nil
+	}, nil
+}
+
+// newFilter returns the filterFunc configured by the FilterParam.
+func newFilter() (filterFunc, erratt.Error) {
+	if expression := FilterParam.Value(); expression != "" {
+		return celFilter(expression)
+	}
+	return acceptAll, nil
+}
@@ -0,0 +1,82 @@
+package export
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+)
+
+var _ = Describe("A CEL filter", func() {
+	It("accepts the resources for which the expression is true", func() {
+		filter, err := celFilter(`object.kind == "Bucket" && object.metadata.name.startsWith("prod-")`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filter(newResource("Bucket", "prod-logs"))).To(BeTrue())
+		Expect(filter(newResource("Bucket", "dev-logs"))).To(BeFalse())
+		Expect(filter(newResource("User", "prod-admin"))).To(BeFalse())
+	})
+	It("evaluates the content of commented resources", func() {
+		filter, err := celFilter(`object.metadata.name == "logs"`)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filter(yaml.NewResourceWithComment(newResource("Bucket", "logs")))).To(BeTrue())
+	})
+	It("rejects expressions that do not compile", func() {
+		_, err := celFilter(`object.metadata.name ==`)
+		Expect(err).To(MatchError(ContainSubstring("Cannot compile filter expression")))
+		_, err = celFilter(`resource.metadata.name == "logs"`)
+		Expect(err).To(MatchError(ContainSubstring("Cannot compile filter expression")))
+	})
+	It("rejects expressions that cannot evaluate to bool", func() {
+		_, err := celFilter(`1 + 1`)
+		Expect(err).To(MatchError(ContainSubstring("must evaluate to bool")))
+	})
+	It("reports the resources for which the expression fails", func() {
+		filter, err := celFilter(`object.spec.region == "eu10"`)
+		Expect(err).NotTo(HaveOccurred())
+		accepted, evalErr := filter(newResource("Bucket", "logs"))
+		Expect(evalErr).To(MatchError(ContainSubstring("cannot evaluate filter expression")))
+		Expect(accepted).To(BeFalse())
+		filter, err = celFilter(`object.metadata.name`)
+		Expect(err).NotTo(HaveOccurred())
+		_, evalErr = filter(newResource("Bucket", "logs"))
+		Expect(evalErr).To(MatchError(ContainSubstring("did not evaluate to bool")))
+	})
+	It("accepts every resource if no expression is configured", func() {
+		filter, err := newFilter()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(filter(newResource("Bucket", "logs"))).To(BeTrue())
+	})
+})
+
+var _ = Describe("Filtering the exported resources", func() {
+	var out *recordingOutput
+	var p *pipeline
+	BeforeEach(func() {
+		setParam(FilterParam, `object.metadata.name != "skipped"`)
+		filter, err := newFilter()
+		Expect(err).NotTo(HaveOccurred())
+		out = &recordingOutput{}
+		p = newTestPipeline(out)
+		p.filter = filter
+	})
+	It("writes the accepted resources only", func() {
+		p.process(newResource("Bucket", "logs"))
+		p.process(newResource("Bucket", "skipped"))
+		Expect(out.resources).To(HaveLen(1))
+		Expect(p.result.Resources).To(Equal(1))
+	})
+	It("drops the warnings of the rejected resources", func() {
+		skipped := newResource("Bucket", "skipped")
+		p.warnings.Add(skipped, errors.New("incomplete"))
+		p.process(skipped)
+		Expect(p.warnings.Take(skipped)).To(BeEmpty())
+	})
+	It("drops and reports the resources for which the expression fails", func() {
+		p.filter, _ = celFilter(`object.spec.region == "eu10"`)
+		p.process(newResource("Bucket", "logs"))
+		Expect(out.resources).To(BeEmpty())
+		Expect(p.result.Warnings).To(Equal(1))
+	})
+})
@@ -0,0 +1,303 @@
+package export
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SAP/xp-clifford/cli"
+	"github.com/SAP/xp-clifford/cli/configparam"
+	"github.com/SAP/xp-clifford/erratt"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+const (
+	// branchTimeFormat is the format of the run timestamp in the
+	// names of the branches created per run.
+	branchTimeFormat = "20060102-150405"
+	// maskedValue replaces the values of sensitive parameters in the
+	// command line recorded in the commit message.
+	maskedValue = "***"
+)
+
+// gitOutput writes the YAML documents into files of a Git working
+// tree like fileOutput, and commits the written files when the output
+// is committed. The tracked files that follow the naming of the output
+// files but are not written by the run, like the files of a kind that
+// is no longer exported, are removed in the same commit. With a
+// branch prefix, the commit is made on a new branch named after the
+// prefix and the time of the commit.
+type gitOutput struct {
+	*fileOutput
+	repo         string
+	branchPrefix string
+	kinds        map[string]int
+	// top is the top-level directory of the working tree. The paths
+	// passed to git are relative to it.
+	top string
+	// dir is the directory of the output files relative to top.
+	dir string
+}
+
+var _ output = &gitOutput{}
+
+func newGitOutput(fo *fileOutput, repo, branchPrefix string) *gitOutput {
+	return &gitOutput{
+		fileOutput:   fo,
+		repo:         repo,
+		branchPrefix: branchPrefix,
+		kinds:        map[string]int{},
+	}
+}
+
+// runGit runs the git command line client in dir and returns its
+// standard output.
+func runGit(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", erratt.Errorf("git %s failed: %w", args[0], err).With("stderr", strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// git runs the git command line client in the top-level directory of
+// the working tree.
+func (g *gitOutput) git(args ...string) (string, error) {
+	return runGit(g.top, args...)
+}
+
+// checkRepository verifies that the repository is a Git working tree
+// and that the output files are placed into it.
+func (g *gitOutput) checkRepository() erratt.Error {
+	top, err := runGit(g.repo, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return erratt.Errorf("Not a Git working tree: %w", err).With("git-repo", g.repo)
+	}
+	g.top = strings.TrimSpace(top)
+	// git reports the top-level directory with the symbolic links
+	// resolved
+	dir, err := filepath.Abs(filepath.Dir(g.path))
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return erratt.Errorf("Cannot access output directory: %w", err).With("output", g.path)
+	}
+	if g.dir, err = filepath.Rel(g.top, dir); err != nil || !filepath.IsLocal(g.dir) {
+		return erratt.New("Output file is outside the Git working tree", "output", g.path, "git-repo", g.repo)
+	}
+	return nil
+}
+
+// gitPath returns the path of the output file name relative to the
+// top-level directory of the working tree.
+func (g *gitOutput) gitPath(name string) string {
+	return filepath.Join(g.dir, filepath.Base(name))
+}
+
+// staleFiles returns the tracked files in the output directory that
+// follow the naming of the split output files, see
+// [fileOutput.filePattern], but are not among the written files, like
+// the file of a kind that is no longer exported. Without splitting,
+// no file is stale, as the single output file is always written.
+func (g *gitOutput) staleFiles(written []string) ([]string, error) {
+	if !g.byKind && !g.rolling() {
+		return nil, nil
+	}
+	out, err := g.git("ls-files", "-z", "--", g.dir)
+	if err != nil {
+		return nil, err
+	}
+	pattern := g.filePattern()
+	var stale []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path == "" || filepath.Dir(path) != g.dir || slices.Contains(written, path) {
+			continue
+		}
+		if pattern.MatchString(filepath.Base(path)) {
+			stale = append(stale, path)
+		}
+	}
+	return stale, nil
+}
+
+// Write writes res like fileOutput and counts it by kind.
+func (g *gitOutput) Write(res resource.Object, render renderFunc) error {
+	if err := g.fileOutput.Write(res, render); err != nil {
+		return err
+	}
+	kind := res.GetObjectKind().GroupVersionKind().Kind
+	if kind == "" {
+		kind = "unknown"
+	}
+	g.kinds[kind]++
+	return nil
+}
+
+// Commit renames the written files to their target names and commits
+// them to the repository, together with the removal of the stale
+// files. No commit is made if the files have not changed.
+func (g *gitOutput) Commit() error {
+	files := make([]string, 0, len(g.written))
+	for _, f := range g.written {
+		files = append(files, g.gitPath(f.name))
+	}
+	if err := g.fileOutput.Commit(); err != nil {
+		return err
+	}
+	if err := g.commitFiles(files); err != nil {
+		return erratt.Errorf("Cannot commit output to Git: %w", err).With("git-repo", g.repo)
+	}
+	return nil
+}
+
+func (g *gitOutput) commitFiles(files []string) error {
+	stale, err := g.staleFiles(files)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(stale) == 0 {
+		return nil
+	}
+	if len(stale) > 0 {
+		if _, err := g.git(append([]string{"rm", "--quiet", "--"}, stale...)...); err != nil {
+			return err
+		}
+		slog.Info("Removed stale output files", "git-repo", g.repo, "files", stale)
+	}
+	pathArgs := append([]string{"--"}, append(files, stale...)...)
+	if len(files) > 0 {
+		if _, err := g.git(append([]string{"add", "--"}, files...)...); err != nil {
+			return err
+		}
+	}
+	// git diff exits with 1 if there are staged changes
+	_, err = g.git(append([]string{"diff", "--cached", "--quiet"}, pathArgs...)...)
+	if err == nil {
+		slog.Info("Output is unchanged, nothing to commit", "git-repo", g.repo)
+		return nil
+	}
+	if exitErr := (*exec.ExitError)(nil); !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+		return err
+	}
+	if g.branchPrefix != "" {
+		branch := g.branchPrefix + time.Now().UTC().Format(branchTimeFormat)
+		if _, err := g.git("switch", "--create", branch); err != nil {
+			return err
+		}
+		slog.Info("Created Git branch", "branch", branch)
+	}
+	args := append([]string{"commit", "--quiet", "--message", g.commitMessage()}, pathArgs...)
+	if _, err := g.git(args...); err != nil {
+		return err
+	}
+	commit, err := g.git("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return err
+	}
+	slog.Info("Committed output to Git", "git-repo", g.repo, "commit", strings.TrimSpace(commit))
+	return nil
+}
+
+// commitMessage returns the message of the commit of a run: the
+// number of the exported resources per kind and the command line.
+func (g *gitOutput) commitMessage() string {
+	total := 0
+	for _, n := range g.kinds {
+		total += n
+	}
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "Export %d resources of %s\n\n", total, cli.Configuration.ObservedSystem)
+	for _, kind := range slices.Sorted(maps.Keys(g.kinds)) {
+		fmt.Fprintf(sb, "%s: %d\n", kind, g.kinds[kind])
+	}
+	fmt.Fprintf(sb, "\nCommand: %s\n", commandLine(os.Args, sensitiveFlags(exportCmd.configParams)))
+	return sb.String()
+}
+
+// sensitiveFlags returns the command line flags of the sensitive
+// parameters in params, like "--password" and "-p".
+func sensitiveFlags(params configparam.ParamList) []string {
+	var flags []string
+	add := func(sensitive bool, flagName string, shortName *string) {
+		if !sensitive {
+			return
+		}
+		flags = append(flags, "--"+flagName)
+		if shortName != nil {
+			flags = append(flags, "-"+*shortName)
+		}
+	}
+	for _, param := range params {
+		switch p := param.(type) {
+		case *configparam.StringParam:
+			add(p.IsSensitive(), p.FlagName, p.ShortName)
+		case *configparam.StringSliceParam:
+			add(p.IsSensitive(), p.FlagName, p.ShortName)
+		}
+	}
+	return flags
+}
+
+// commandLine returns args as a shell command line. The values of the
+// given flags are masked.
+func commandLine(args []string, masked []string) string {
+	quoted := make([]string, 0, len(args))
+	maskNext := false
+	for i, arg := range args {
+		switch {
+		case i == 0:
+			arg = filepath.Base(arg)
+		case maskNext:
+			arg = maskedValue
+			maskNext = false
+		case slices.Contains(masked, arg):
+			maskNext = true
+		default:
+			arg = maskValue(arg, masked)
+		}
+		quoted = append(quoted, shellQuote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// maskValue masks the value of arg, if it is one of the given flags
+// with its value, like "--password=secret" or "-psecret".
+func maskValue(arg string, masked []string) string {
+	for _, flag := range masked {
+		value, ok := strings.CutPrefix(arg, flag)
+		switch {
+		case !ok || value == "":
+			continue
+		case strings.HasPrefix(value, "="):
+			return flag + "=" + maskedValue
+		case !strings.HasPrefix(flag, "--"):
+			return flag + maskedValue
+		}
+	}
+	return arg
+}
+
+// shellSafe matches the arguments that need no quoting in a shell.
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes arg for a POSIX shell, if needed.
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
@@ -0,0 +1,141 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/cli/configparam"
+)
+
+// gitLines runs git in repo and returns the lines of its output.
+func gitLines(repo string, args ...string) []string {
+	out, err := runGit(repo, args...)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	out = strings.TrimSpace(out)
+	if out == "" {
+		return []string{}
+	}
+	return strings.Split(out, "\n")
+}
+
+// exportToGit writes the resources of the given kinds into the
+// output configured by the parameters and commits it.
+func exportToGit(kinds ...string) {
+	out, err := openOutput(nil)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	writeResources(out, kinds...)
+	ExpectWithOffset(1, out.Commit()).To(Succeed())
+}
+
+var _ = Describe("The Git output", func() {
+	var repo string
+	BeforeEach(func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			Skip("git is not installed")
+		}
+		GinkgoT().Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+		GinkgoT().Setenv("GIT_AUTHOR_NAME", "Exporter")
+		GinkgoT().Setenv("GIT_AUTHOR_EMAIL", "exporter@example.com")
+		GinkgoT().Setenv("GIT_COMMITTER_NAME", "Exporter")
+		GinkgoT().Setenv("GIT_COMMITTER_EMAIL", "exporter@example.com")
+		repo = GinkgoT().TempDir()
+		gitLines(repo, "init", "--quiet")
+		setParam(GitRepoParam, repo)
+		setParam(OutputParam, "out.yaml")
+		setParam(ForceParam, true)
+	})
+
+	It("commits the output files", func() {
+		exportToGit("Bucket", "Bucket", "User")
+		Expect(gitLines(repo, "ls-files")).To(Equal([]string{"out.yaml"}))
+		message := gitLines(repo, "log", "--format=%B", "-1")
+		Expect(message[0]).To(HavePrefix("Export 3 resources of "))
+		Expect(message).To(ContainElements("Bucket: 2", "User: 1"))
+	})
+
+	It("makes no commit if the output has not changed", func() {
+		exportToGit("Bucket")
+		exportToGit("Bucket")
+		Expect(gitLines(repo, "log", "--oneline")).To(HaveLen(1))
+	})
+
+	It("places a relative output path into the working tree", func() {
+		Expect(os.Mkdir(filepath.Join(repo, "exports"), 0o755)).To(Succeed())
+		setParam(OutputParam, filepath.Join("exports", "out.yaml"))
+		exportToGit("Bucket")
+		Expect(gitLines(repo, "ls-files")).To(Equal([]string{"exports/out.yaml"}))
+	})
+
+	It("removes the stale output files of earlier runs", func() {
+		Expect(os.WriteFile(filepath.Join(repo, "notes.yaml"), []byte("notes\n"), 0o600)).To(Succeed())
+		gitLines(repo, "add", "notes.yaml")
+		gitLines(repo, "commit", "--quiet", "--message", "Add notes")
+		setParam(SplitByParam, splitByKind)
+		exportToGit("Bucket", "User")
+		Expect(gitLines(repo, "ls-files")).To(ConsistOf("notes.yaml", "out-bucket.yaml", "out-user.yaml"))
+		exportToGit("Bucket", "Bucket")
+		Expect(gitLines(repo, "ls-files")).To(ConsistOf("notes.yaml", "out-bucket.yaml"))
+		Expect(outputFiles(repo)).NotTo(ContainElement("out-user.yaml"))
+		Expect(gitLines(repo, "status", "--porcelain")).To(BeEmpty())
+	})
+
+	It("keeps the tracked files that the output does not write", func() {
+		Expect(os.WriteFile(filepath.Join(repo, "out-prod.yaml"), []byte("---\nkind: Old\n...\n"), 0o600)).To(Succeed())
+		gitLines(repo, "add", "out-prod.yaml")
+		gitLines(repo, "commit", "--quiet", "--message", "Add production export")
+		exportToGit("Bucket")
+		Expect(gitLines(repo, "ls-files")).To(ConsistOf("out.yaml", "out-prod.yaml"))
+		By("removing only the numbered files with rolling output")
+		setParam(SplitResourcesParam, 1)
+		exportToGit("Bucket", "User")
+		exportToGit("Bucket")
+		Expect(gitLines(repo, "ls-files")).To(ConsistOf("out.yaml", "out-prod.yaml", "out-0001.yaml"))
+		Expect(gitLines(repo, "status", "--porcelain")).To(BeEmpty())
+	})
+
+	It("rejects an output file outside the working tree up front", func() {
+		outside := filepath.Join(GinkgoT().TempDir(), "out.yaml")
+		setParam(OutputParam, outside)
+		_, err := openOutput(nil)
+		Expect(err).To(MatchError("Output file is outside the Git working tree"))
+		Expect(outputFiles(filepath.Dir(outside))).To(BeEmpty())
+	})
+
+	It("rejects a directory that is not a working tree", func() {
+		setParam(GitRepoParam, GinkgoT().TempDir())
+		_, err := openOutput(nil)
+		Expect(err).To(MatchError(ContainSubstring("Not a Git working tree")))
+	})
+
+	It("keeps the partial output of a failed run without a commit", func() {
+		setParam(OnErrorParam, onErrorKeep)
+		out, err := openOutput(nil)
+		Expect(err).NotTo(HaveOccurred())
+		p := newTestPipeline(out)
+		runErr := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newResource("Bucket", "logs"))
+			return errors.New("connection lost")
+		})
+		Expect(runErr).To(MatchError("connection lost"))
+		Expect(documents(filepath.Join(repo, "out.yaml"))).To(Equal(1))
+		Expect(gitLines(repo, "status", "--porcelain")).To(Equal([]string{"?? out.yaml"}))
+		_, gitErr := runGit(repo, "rev-parse", "HEAD")
+		Expect(gitErr).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("The command line in the commit message", func() {
+	It("masks the values of the sensitive flags", func() {
+		password := configparam.SensitiveString("password", "password").WithFlagName("password").WithShortName("p")
+		masked := sensitiveFlags(configparam.ParamList{password})
+		Expect(commandLine([]string{"/usr/bin/exporter", "export", "-p", "secret", "--password=secret", "-psecret", "--kind", "it's"}, masked)).
+			To(Equal(`exporter export -p '***' '--password=***' '-p***' --kind 'it'\''s'`))
+	})
+})
@@ -0,0 +1,129 @@
+// Package warning tracks the warnings reported for resources and adds
+// them to the resources as comments. It is shared by the export
+// subcommand and the exporttest package.
+package warning
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// blockingError marks a warning as blocking.
+type blockingError struct {
+	error
+}
+
+func (e blockingError) Unwrap() error {
+	return e.error
+}
+
+// Blocking marks err as a blocking warning.
+func Blocking(err error) error {
+	return blockingError{err}
+}
+
+// IsBlocking reports whether err is marked as a blocking warning.
+func IsBlocking(err error) bool {
+	return errors.As(err, &blockingError{})
+}
+
+// Text formats err and its attributes as a single line.
+func Text(err error) string {
+	sb := &strings.Builder{}
+	if IsBlocking(err) {
+		sb.WriteString("BLOCKING ")
+	}
+	fmt.Fprintf(sb, "WARNING: %s", err.Error())
+	var attrErr interface{ Attrs() []any }
+	if errors.As(err, &attrErr) {
+		attrs := attrErr.Attrs()
+		for i := 0; i+1 < len(attrs); i += 2 {
+			fmt.Fprintf(sb, " %v=%v", attrs[i], attrs[i+1])
+		}
+	}
+	return sb.String()
+}
+
+// Tracker collects the warnings reported for the resources that have
+// not been processed yet. It is safe for concurrent use.
+type Tracker struct {
+	mu       sync.Mutex
+	warnings map[resource.Object][]error
+}
+
+// NewTracker returns an empty Tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		warnings: map[resource.Object][]error{},
+	}
+}
+
+// key returns the key of res in the warnings map. Resources are
+// identified by their unwrapped value, which is usually a pointer.
+// Resources that cannot be used as a map key are not tracked.
+func key(res resource.Object) (resource.Object, bool) {
+	if r, ok := res.(*yaml.ResourceWithComment); ok {
+		res = r.Resource()
+	}
+	if res == nil || !reflect.TypeOf(res).Comparable() {
+		return nil, false
+	}
+	return res, true
+}
+
+// Add records the warning err reported for res.
+func (t *Tracker) Add(res resource.Object, err error) {
+	k, ok := key(res)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.warnings[k] = append(t.warnings[k], err)
+}
+
+// Take removes and returns the warnings reported for res.
+func (t *Tracker) Take(res resource.Object) []error {
+	k, ok := key(res)
+	if !ok {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	errs := t.warnings[k]
+	delete(t.warnings, k)
+	return errs
+}
+
+// Annotate adds the warnings recorded for res as comments and forgets
+// them, see [Annotate].
+func (t *Tracker) Annotate(res resource.Object) resource.Object {
+	return Annotate(res, t.Take(res)...)
+}
+
+// Annotate adds the warnings as comments to res. If any of the
+// warnings is blocking, the resource is commented out. Without
+// warnings, res is returned unchanged.
+func Annotate(res resource.Object, errs ...error) resource.Object {
+	if len(errs) == 0 {
+		return res
+	}
+	commented, ok := res.(*yaml.ResourceWithComment)
+	if !ok {
+		commented = yaml.NewResourceWithComment(res)
+	}
+	_, commentOut := commented.Comment()
+	for _, err := range errs {
+		commented.AddComment(Text(err))
+		commentOut = commentOut || IsBlocking(err)
+	}
+	commented.SetCommentOut(commentOut)
+	return commented
+}
@@ -0,0 +1,24 @@
+package warning
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestWarning(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Warning Suite")
+}
+
+// newResource returns an unstructured resource of the given kind.
+func newResource(kind, name string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.example.com/v1",
+		"kind":       kind,
+		"metadata":   map[string]any{"name": name},
+	}}
+}
@@ -0,0 +1,82 @@
+package warning
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+)
+
+var _ = Describe("Blocking warnings", func() {
+	It("are recognized through wrapping", func() {
+		err := Blocking(errors.New("missing reference"))
+		Expect(IsBlocking(err)).To(BeTrue())
+		Expect(IsBlocking(fmt.Errorf("wrapped: %w", err))).To(BeTrue())
+		Expect(IsBlocking(errors.New("missing reference"))).To(BeFalse())
+	})
+
+	It("are formatted with their attributes", func() {
+		Expect(Text(errors.New("deprecated field"))).To(Equal("WARNING: deprecated field"))
+		Expect(Text(Blocking(erratt.New("missing reference", "ref", "vpc-1")))).
+			To(Equal("BLOCKING WARNING: missing reference ref=vpc-1"))
+	})
+})
+
+var _ = Describe("Annotate", func() {
+	It("returns the resource unchanged without warnings", func() {
+		res := newResource("Bucket", "logs")
+		Expect(Annotate(res)).To(BeIdenticalTo(res))
+	})
+
+	It("adds the warnings as comments", func() {
+		res := newResource("Bucket", "logs")
+		annotated := Annotate(res, errors.New("deprecated field"), errors.New("no owner"))
+		commented, ok := annotated.(*yaml.ResourceWithComment)
+		Expect(ok).To(BeTrue())
+		Expect(commented.Resource()).To(BeIdenticalTo(res))
+		comment, commentOut := commented.Comment()
+		Expect(comment).To(Equal("WARNING: deprecated field\nWARNING: no owner\n"))
+		Expect(commentOut).To(BeFalse())
+	})
+
+	It("comments out the resource with a blocking warning", func() {
+		annotated := Annotate(newResource("Bucket", "logs"),
+			errors.New("deprecated field"),
+			Blocking(errors.New("missing reference")))
+		_, commentOut := annotated.(*yaml.ResourceWithComment).Comment()
+		Expect(commentOut).To(BeTrue())
+	})
+
+	It("keeps the comment of a commented resource", func() {
+		commented := yaml.NewResourceWithComment(newResource("Bucket", "logs"))
+		commented.SetComment("exported manually")
+		commented.SetCommentOut(true)
+		annotated := Annotate(commented, errors.New("deprecated field"))
+		Expect(annotated).To(BeIdenticalTo(commented))
+		comment, commentOut := commented.Comment()
+		Expect(comment).To(Equal("exported manually\nWARNING: deprecated field\n"))
+		Expect(commentOut).To(BeTrue())
+	})
+})
+
+var _ = Describe("The warning tracker", func() {
+	It("are taken once for a resource", func() {
+		w := NewTracker()
+		res := newResource("Bucket", "logs")
+		w.Add(res, errors.New("deprecated field"))
+		w.Add(newResource("Bucket", "logs"), errors.New("other resource"))
+		Expect(w.Take(res)).To(HaveLen(1))
+		Expect(w.Take(res)).To(BeEmpty())
+	})
+
+	It("identify a wrapped resource by the resource it wraps", func() {
+		w := NewTracker()
+		res := newResource("Bucket", "logs")
+		w.Add(res, errors.New("deprecated field"))
+		Expect(w.Take(yaml.NewResourceWithComment(res))).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"strings"
+
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+)
+
+// RemoveFields configures the export subcommand to remove the fields
+// identified by paths from the exported resources of the given kind,
+// or of every kind if kind is empty. The paths are dot-separated,
+// see [yaml.Normalizer]:
+//
+//	export.RemoveFields("Bucket", "spec.forProvider.tags", "metadata.annotations")
+func RemoveFields(kind string, paths ...string) {
+	if exportCmd.removedFields == nil {
+		exportCmd.removedFields = map[string][]string{}
+	}
+	exportCmd.removedFields[kind] = append(exportCmd.removedFields[kind], paths...)
+}
+
+// newNormalizer returns the normalizer configured by the
+// NormalizeParam, the RemoveFieldsParam and removedFields, or nil if
+// no field is removed. The values of the RemoveFieldsParam have the
+// form "[KIND:]PATH".
+func newNormalizer(removedFields map[string][]string) (*yaml.Normalizer, erratt.Error) {
+	removeFields := RemoveFieldsParam.Value()
+	if !NormalizeParam.Value() && len(removeFields) == 0 && len(removedFields) == 0 {
+		return nil, nil
+	}
+	n := yaml.NewNormalizer()
+	if NormalizeParam.Value() {
+		n = yaml.DefaultNormalizer()
+	}
+	for kind, paths := range removedFields {
+		if kind == "" {
+			n.WithRemovedPaths(paths...)
+		} else {
+			n.WithRemovedKindPaths(kind, paths...)
+		}
+	}
+	for _, field := range removeFields {
+		kind, path, ok := strings.Cut(field, ":")
+		if !ok {
+			kind, path = "", field
+		}
+		if path == "" {
+			return nil, erratt.New("Invalid remove-fields value", "remove-fields", field)
+		}
+		if kind == "" {
+			n.WithRemovedPaths(path)
+		} else {
+			n.WithRemovedKindPaths(kind, path)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// newTaggedResource returns a resource with status, labels and tags.
+func newTaggedResource(kind, name string) *unstructured.Unstructured {
+	res := newResource(kind, name)
+	res.Object["metadata"].(map[string]any)["labels"] = map[string]any{"team": "core"}
+	res.Object["spec"] = map[string]any{"tags": []any{"a"}, "size": int64(1)}
+	res.Object["status"] = map[string]any{"ready": true}
+	return res
+}
+
+var _ = Describe("newNormalizer", func() {
+	normalize := func(removedFields map[string][]string, res *unstructured.Unstructured) map[string]any {
+		n, err := newNormalizer(removedFields)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		ExpectWithOffset(1, n).NotTo(BeNil())
+		normalized, normalizeErr := n.NormalizeResource(res)
+		ExpectWithOffset(1, normalizeErr).NotTo(HaveOccurred())
+		return normalized.(*unstructured.Unstructured).Object
+	}
+
+	It("returns no normalizer without configuration", func() {
+		Expect(newNormalizer(nil)).To(BeNil())
+	})
+
+	It("applies the default normalization", func() {
+		setParam(NormalizeParam, true)
+		Expect(normalize(nil, newTaggedResource("Bucket", "logs"))).NotTo(HaveKey("status"))
+	})
+
+	It("removes the fields of the remove-fields parameter", func() {
+		setParam(RemoveFieldsParam, []string{"metadata.labels", "Bucket:spec.tags"})
+		bucket := normalize(nil, newTaggedResource("Bucket", "logs"))
+		Expect(bucket["metadata"]).NotTo(HaveKey("labels"))
+		Expect(bucket["spec"]).To(Equal(map[string]any{"size": int64(1)}))
+		Expect(bucket).To(HaveKey("status"))
+		user := normalize(nil, newTaggedResource("User", "admin"))
+		Expect(user["metadata"]).NotTo(HaveKey("labels"))
+		Expect(user["spec"]).To(HaveKey("tags"))
+	})
+
+	It("removes the fields registered with RemoveFields", func() {
+		removedFields := exportCmd.removedFields
+		DeferCleanup(func() {
+			exportCmd.removedFields = removedFields
+		})
+		exportCmd.removedFields = nil
+		RemoveFields("Bucket", "spec.tags")
+		RemoveFields("", "status")
+		Expect(exportCmd.removedFields).To(Equal(map[string][]string{"Bucket": {"spec.tags"}, "": {"status"}}))
+		bucket := normalize(exportCmd.removedFields, newTaggedResource("Bucket", "logs"))
+		Expect(bucket).NotTo(HaveKey("status"))
+		Expect(bucket["spec"]).NotTo(HaveKey("tags"))
+	})
+
+	It("rejects a value without a path", func() {
+		setParam(RemoveFieldsParam, []string{"Bucket:"})
+		_, err := newNormalizer(nil)
+		Expect(err).To(MatchError("Invalid remove-fields value"))
+	})
+
+	It("normalizes the resources in the pipeline", func() {
+		setParam(NormalizeParam, true)
+		out := &recordingOutput{}
+		p := newTestPipeline(out)
+		var err error
+		p.normalizer, err = newNormalizer(nil)
+		Expect(err).NotTo(HaveOccurred())
+		runErr := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newTaggedResource("Bucket", "logs"))
+			return nil
+		})
+		Expect(runErr).NotTo(HaveOccurred())
+		Expect(out.resources).To(HaveLen(1))
+		Expect(out.resources[0].(*unstructured.Unstructured).Object).NotTo(HaveKey("status"))
+	})
+})
@@ -0,0 +1,527 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+const (
+	splitByKind = "kind"
+
+	onErrorKeep    = "keep"
+	onErrorDiscard = "discard"
+
+	megabyte = 1024 * 1024
+
+	outputFileMode = 0o644
+)
+
+// output is the destination of the YAML documents generated during
+// an export run.
+type output interface {
+	// Write stores the document of res written by render.
+	Write(res resource.Object, render renderFunc) error
+	// Commit makes the written documents persistent. It is invoked
+	// when the export run succeeds.
+	Commit() error
+	// Abort discards the written documents. It is invoked when the
+	// export run fails or is interrupted.
+	Abort() error
+}
+
+// consoleOutput prints the YAML documents on the standard output.
+type consoleOutput struct{}
+
+var _ output = consoleOutput{}
+
+func (consoleOutput) Write(res resource.Object, render renderFunc) error {
+	w := bufio.NewWriter(os.Stdout)
+	if err := render(w, res); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func (consoleOutput) Commit() error {
+	return nil
+}
+
+func (consoleOutput) Abort() error {
+	return nil
+}
+
+// fileWriter writes to a file and keeps the first write error.
+type fileWriter struct {
+	file *os.File
+	err  error
+}
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	if fw.err != nil {
+		return 0, fw.err
+	}
+	var n int
+	n, fw.err = fw.file.Write(p)
+	return n, fw.err
+}
+
+// outputFile is a single file of a fileOutput. The documents are
+// written into a temporary file next to the target, which is renamed
+// to name when the output is committed.
+type outputFile struct {
+	name      string
+	file      *os.File
+	fw        *fileWriter
+	w         *bufio.Writer
+	index     int
+	resources int
+	bytes     int64
+}
+
+// close flushes the buffered documents and closes the file.
+func (f *outputFile) close() error {
+	if err := f.w.Flush(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+// fileOutput writes the YAML documents into one or more files. When
+// splitting is configured, the file names are derived from path by
+// inserting the lowercased resource kind and/or a sequence number
+// before the extension, e.g. out-bucket-0002.yaml.
+//
+// Existing files are left untouched until the output is committed.
+// They are replaced only if force is set or they are replaceable, and
+// extended if append is set. If encrypter is set, the files are
+// sealed by it when the output is committed.
+type fileOutput struct {
+	path         string
+	byKind       bool
+	maxResources int
+	maxBytes     int64
+	force        bool
+	append       bool
+	// replaceable are the files written by the previous run in watch
+	// mode, which are replaced without force.
+	replaceable []string
+	encrypter   *yaml.Encrypter
+	files       map[string]*outputFile
+	written     []*outputFile
+	// committed are the names of the files of the last commit.
+	committed []string
+	err       erratt.Error
+}
+
+var _ output = &fileOutput{}
+
+func newFileOutput(path string, byKind bool, maxResources int, maxBytes int64) *fileOutput {
+	return &fileOutput{
+		path:         filepath.Clean(path),
+		byKind:       byKind,
+		maxResources: maxResources,
+		maxBytes:     maxBytes,
+		files:        map[string]*outputFile{},
+	}
+}
+
+func (o *fileOutput) rolling() bool {
+	return o.maxResources > 0 || o.maxBytes > 0
+}
+
+func (o *fileOutput) fileName(kind string, index int) string {
+	if !o.byKind && !o.rolling() {
+		return o.path
+	}
+	ext := filepath.Ext(o.path)
+	parts := []string{strings.TrimSuffix(o.path, ext)}
+	if o.byKind {
+		parts = append(parts, kind)
+	}
+	if o.rolling() {
+		parts = append(parts, fmt.Sprintf("%04d", index))
+	}
+	return strings.Join(parts, "-") + ext
+}
+
+// filePattern matches the base names of the files that o may write,
+// like "out-bucket-0002.yaml" when the output is split by kind and
+// number of resources.
+func (o *fileOutput) filePattern() *regexp.Regexp {
+	ext := filepath.Ext(o.path)
+	pattern := regexp.QuoteMeta(strings.TrimSuffix(filepath.Base(o.path), ext))
+	if o.byKind {
+		pattern += `-[a-z0-9]+`
+	}
+	if o.rolling() {
+		pattern += `-[0-9]{4,}`
+	}
+	return regexp.MustCompile("^" + pattern + regexp.QuoteMeta(ext) + "$")
+}
+
+// existingFiles returns the files in the output directory that o may
+// overwrite, see filePattern.
+func (o *fileOutput) existingFiles() ([]string, error) {
+	dir := filepath.Dir(o.path)
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pattern := o.filePattern()
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && pattern.MatchString(entry.Name()) {
+			names = append(names, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return names, nil
+}
+
+// isFull reports whether f cannot take a document of size n without
+// exceeding the configured limits. An empty file is never full, so
+// documents larger than the size limit still get written.
+func (o *fileOutput) isFull(f *outputFile, n int) bool {
+	if f.resources == 0 {
+		return false
+	}
+	if o.maxResources > 0 && f.resources >= o.maxResources {
+		return true
+	}
+	return o.maxBytes > 0 && f.bytes+int64(n) > o.maxBytes
+}
+
+// copyExisting copies the content of the existing file name into
+// tmp. It returns the number of copied bytes.
+func copyExisting(name string, tmp *os.File) (int64, error) {
+	existing, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer func() {
+		_ = existing.Close()
+	}()
+	if info, err := existing.Stat(); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			return 0, err
+		}
+	}
+	return io.Copy(tmp, existing)
+}
+
+func (o *fileOutput) openFile(kind string, index int) (*outputFile, erratt.Error) {
+	name := o.fileName(kind, index)
+	if _, err := os.Stat(name); err == nil && !o.force && !o.append && !slices.Contains(o.replaceable, name) {
+		return nil, erratt.New("Output file already exists, use --force to overwrite or --append to extend it", "output", name)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, erratt.Errorf("Cannot create output file: %w", err).With("output", name)
+	}
+	fw := &fileWriter{file: tmp}
+	f := &outputFile{
+		name:  name,
+		file:  tmp,
+		fw:    fw,
+		w:     bufio.NewWriter(fw),
+		index: index,
+	}
+	o.written = append(o.written, f)
+	if err := tmp.Chmod(outputFileMode); err != nil {
+		return nil, erratt.Errorf("Cannot create output file: %w", err).With("output", name)
+	}
+	if o.append {
+		if f.bytes, err = copyExisting(name, tmp); err != nil {
+			return nil, erratt.Errorf("Cannot read existing output file: %w", err).With("output", name)
+		}
+	}
+	slog.Info("Writing output to file", "output", name)
+	return f, nil
+}
+
+// open checks that no file that o may write exists already, unless
+// force or append is set, and creates the output file in advance when
+// the output is not split. Split files are created when the first
+// document is written into them, so they are checked up front rather
+// than failing the export partway through.
+func (o *fileOutput) open() erratt.Error {
+	if !o.force && !o.append {
+		existing, err := o.existingFiles()
+		if err != nil {
+			return erratt.Errorf("Cannot read output directory: %w", err).With("output", o.path)
+		}
+		existing = slices.DeleteFunc(existing, func(name string) bool {
+			return slices.Contains(o.replaceable, name)
+		})
+		if len(existing) > 0 {
+			return erratt.New("Output file already exists, use --force to overwrite or --append to extend it", "output", strings.Join(existing, ", "))
+		}
+	}
+	if o.byKind || o.rolling() {
+		return nil
+	}
+	f, err := o.openFile("", 1)
+	if err != nil {
+		_ = o.Abort()
+		return err
+	}
+	o.files[""] = f
+	return nil
+}
+
+// file returns the file that the next document of the given kind
+// and size n shall be written into.
+func (o *fileOutput) file(kind string, n int) (*outputFile, erratt.Error) {
+	index := 1
+	if f, ok := o.files[kind]; ok {
+		if !o.isFull(f, n) {
+			return f, nil
+		}
+		delete(o.files, kind)
+		if err := f.close(); err != nil {
+			return nil, erratt.Errorf("Cannot close output file: %w", err).With("output", f.name)
+		}
+		index = f.index + 1
+	}
+	f, err := o.openFile(kind, index)
+	if err != nil {
+		return nil, err
+	}
+	o.files[kind] = f
+	return f, nil
+}
+
+// Write renders res into the file that belongs to it. The documents
+// are streamed into the file, unless a size limit is configured: the
+// size of a document is needed to choose its file, so it is rendered
+// into memory first.
+//
+// Once writing has failed, the output is considered broken: the
+// subsequent documents are rejected with the same error and Commit
+// discards the output.
+func (o *fileOutput) Write(res resource.Object, render renderFunc) error {
+	if o.err != nil {
+		return o.err
+	}
+	kind := ""
+	if o.byKind {
+		kind = resourceKind(res)
+	}
+	var doc *bytes.Buffer
+	if o.maxBytes > 0 {
+		doc = &bytes.Buffer{}
+		w := bufio.NewWriter(doc)
+		if err := render(w, res); err != nil {
+			return err
+		}
+		// writing into a bytes.Buffer does not fail
+		_ = w.Flush()
+	}
+	n := 0
+	if doc != nil {
+		n = doc.Len()
+	}
+	f, err := o.file(kind, n)
+	if err != nil {
+		o.err = err
+		return err
+	}
+	if doc != nil {
+		_, _ = doc.WriteTo(f.w)
+	} else if err := render(f.w, res); err != nil && f.fw.err == nil {
+		return err
+	}
+	if f.fw.err != nil {
+		o.err = erratt.Errorf("cannot write YAML to output: %w", f.fw.err).With("output", f.name)
+		return o.err
+	}
+	f.resources++
+	f.bytes += int64(n)
+	return nil
+}
+
+// seal replaces the temporary file of f with a copy sealed by the
+// encrypter, which adds the SOPS metadata.
+func (o *fileOutput) seal(f *outputFile) error {
+	in, err := os.Open(f.file.Name())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	tmp, err := os.CreateTemp(filepath.Dir(f.name), "."+filepath.Base(f.name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	err = o.encrypter.Seal(in, w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = tmp.Chmod(outputFileMode)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), f.file.Name())
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Commit closes the temporary files, seals them if encryption is
+// configured and renames them to their target names. If writing has
+// failed before, the temporary files are removed and the error is
+// returned.
+func (o *fileOutput) Commit() error {
+	if o.err != nil {
+		_ = o.Abort()
+		return erratt.Errorf("output is discarded: %w", o.err)
+	}
+	for _, f := range o.written {
+		if err := f.close(); err != nil && !errors.Is(err, os.ErrClosed) {
+			_ = o.Abort()
+			return erratt.Errorf("Cannot close output file: %w", err).With("output", f.name)
+		}
+		if o.encrypter == nil {
+			continue
+		}
+		if err := o.seal(f); err != nil {
+			_ = o.Abort()
+			return erratt.Errorf("Cannot encrypt output file: %w", err).With("output", f.name)
+		}
+	}
+	for i, f := range o.written {
+		if err := os.Rename(f.file.Name(), f.name); err != nil {
+			o.written = o.written[i:]
+			_ = o.Abort()
+			return erratt.Errorf("Cannot rename output file: %w", err).With("output", f.name)
+		}
+	}
+	o.committed = nil
+	for _, f := range o.written {
+		o.committed = append(o.committed, f.name)
+	}
+	o.written = nil
+	o.files = map[string]*outputFile{}
+	return nil
+}
+
+// Abort closes and removes the temporary files, leaving the existing
+// output files untouched.
+func (o *fileOutput) Abort() error {
+	var errs []error
+	for _, f := range o.written {
+		_ = f.file.Close()
+		if err := os.Remove(f.file.Name()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, erratt.Errorf("Cannot remove temporary output file: %w", err).With("output", f.file.Name()))
+		}
+	}
+	o.written = nil
+	o.files = map[string]*outputFile{}
+	return errors.Join(errs...)
+}
+
+// committedFiles returns the names of the files of the last commit of
+// out, if it writes files.
+func committedFiles(out output) []string {
+	switch o := out.(type) {
+	case *fileOutput:
+		return o.committed
+	case *gitOutput:
+		return o.committed
+	}
+	return nil
+}
+
+// resourceKind returns the lowercased kind of res, or "unknown" if
+// the kind is not set.
+func resourceKind(res resource.Object) string {
+	if kind := res.GetObjectKind().GroupVersionKind().Kind; kind != "" {
+		return strings.ToLower(kind)
+	}
+	return "unknown"
+}
+
+// openOutput creates the output configured by the OutputParam, the
+// split parameters and the Git parameters. The output files are sealed by encrypter, if
+// it is not nil. The replaceable files are replaced without the
+// ForceParam, see [fileOutput].
+func openOutput(encrypter *yaml.Encrypter, replaceable ...string) (output, erratt.Error) {
+	o := OutputParam.Value()
+	repo := GitRepoParam.Value()
+	branchPrefix := GitBranchPrefixParam.Value()
+	splitBy := SplitByParam.Value()
+	maxResources := SplitResourcesParam.Value()
+	maxMegabytes := SplitSizeParam.Value()
+	switch onError := OnErrorParam.Value(); {
+	case onError != onErrorKeep && onError != onErrorDiscard:
+		return nil, erratt.New("Invalid on-error value", "on-error", onError, "supported", []string{onErrorKeep, onErrorDiscard})
+	case ForceParam.Value() && AppendParam.Value():
+		return nil, erratt.New("The force and append options cannot be used together")
+	case splitBy != "" && splitBy != splitByKind:
+		return nil, erratt.New("Invalid split-by value", "split-by", splitBy, "supported", splitByKind)
+	case maxResources < 0:
+		return nil, erratt.New("Invalid split-resources value", "split-resources", maxResources)
+	case maxMegabytes < 0:
+		return nil, erratt.New("Invalid split-size value", "split-size", maxMegabytes)
+	}
+	if o == "" {
+		if splitBy != "" || maxResources > 0 || maxMegabytes > 0 {
+			return nil, erratt.New("Splitting the output requires an output file")
+		}
+		if repo != "" {
+			return nil, erratt.New("Committing the output to Git requires an output file")
+		}
+		return consoleOutput{}, nil
+	}
+	if repo == "" && branchPrefix != "" {
+		return nil, erratt.New("The git-branch-prefix option requires a Git repository, use --git-repo")
+	}
+	if repo != "" && !filepath.IsAbs(o) {
+		// the output file is placed into the working tree
+		o = filepath.Join(repo, o)
+	}
+	fo := newFileOutput(o, splitBy == splitByKind, maxResources, int64(maxMegabytes)*megabyte)
+	fo.force = ForceParam.Value()
+	fo.replaceable = replaceable
+	fo.append = AppendParam.Value()
+	fo.encrypter = encrypter
+	var out output = fo
+	if repo != "" {
+		g := newGitOutput(fo, repo, branchPrefix)
+		if err := g.checkRepository(); err != nil {
+			return nil, err
+		}
+		out = g
+	}
+	if err := fo.open(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
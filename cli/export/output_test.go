@@ -0,0 +1,234 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// writeResources writes the resources of the given kinds, named
+// after their position, into o.
+func writeResources(o output, kinds ...string) {
+	for i, kind := range kinds {
+		res := newResource(kind, strings.ToLower(kind)+"-"+string(rune('a'+i)))
+		ExpectWithOffset(1, o.Write(res, yamlRenderer())).To(Succeed())
+	}
+}
+
+// documents returns the number of YAML documents in the file name.
+func documents(name string) int {
+	b, err := os.ReadFile(name)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return strings.Count(string(b), "---\n")
+}
+
+// outputFiles returns the names of the files in dir.
+func outputFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+var _ = Describe("A fileOutput", func() {
+	var dir, path string
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "out.yaml")
+	})
+
+	Describe("splitting the output", func() {
+		It("writes a single file without splitting", func() {
+			o := newFileOutput(path, false, 0, 0)
+			Expect(o.open()).To(Succeed())
+			writeResources(o, "Bucket", "User")
+			Expect(o.Commit()).To(Succeed())
+			Expect(outputFiles(dir)).To(ConsistOf("out.yaml"))
+			Expect(documents(path)).To(Equal(2))
+		})
+		It("writes a file per kind", func() {
+			o := newFileOutput(path, true, 0, 0)
+			Expect(o.open()).To(Succeed())
+			writeResources(o, "Bucket", "User", "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			Expect(outputFiles(dir)).To(ConsistOf("out-bucket.yaml", "out-user.yaml"))
+			Expect(documents(filepath.Join(dir, "out-bucket.yaml"))).To(Equal(2))
+			Expect(documents(filepath.Join(dir, "out-user.yaml"))).To(Equal(1))
+		})
+		It("starts a new file after the given number of resources", func() {
+			o := newFileOutput(path, false, 2, 0)
+			writeResources(o, "Bucket", "Bucket", "Bucket", "Bucket", "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			Expect(outputFiles(dir)).To(ConsistOf("out-0001.yaml", "out-0002.yaml", "out-0003.yaml"))
+			Expect(documents(filepath.Join(dir, "out-0003.yaml"))).To(Equal(1))
+		})
+		It("numbers the files of each kind", func() {
+			o := newFileOutput(path, true, 1, 0)
+			writeResources(o, "Bucket", "User", "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			Expect(outputFiles(dir)).To(ConsistOf("out-bucket-0001.yaml", "out-bucket-0002.yaml", "out-user-0001.yaml"))
+		})
+		It("starts a new file before the size limit is exceeded", func() {
+			o := newFileOutput(path, false, 0, 120)
+			writeResources(o, "Bucket", "Bucket", "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			// a document is about 80 bytes long
+			Expect(outputFiles(dir)).To(ConsistOf("out-0001.yaml", "out-0002.yaml", "out-0003.yaml"))
+		})
+		It("writes documents larger than the size limit", func() {
+			o := newFileOutput(path, false, 0, 10)
+			writeResources(o, "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			Expect(documents(filepath.Join(dir, "out-0001.yaml"))).To(Equal(1))
+		})
+	})
+})
+
+var _ = Describe("Writing the output atomically", func() {
+	var dir, path string
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		path = filepath.Join(dir, "out.yaml")
+	})
+
+	It("renames the written file to its name on commit", func() {
+		o := newFileOutput(path, false, 0, 0)
+		Expect(o.open()).To(Succeed())
+		writeResources(o, "Bucket")
+		Expect(path).NotTo(BeAnExistingFile())
+		Expect(o.Commit()).To(Succeed())
+		Expect(outputFiles(dir)).To(ConsistOf("out.yaml"))
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(outputFileMode)))
+	})
+	It("removes the temporary file on abort", func() {
+		o := newFileOutput(path, false, 0, 0)
+		Expect(o.open()).To(Succeed())
+		writeResources(o, "Bucket")
+		Expect(o.Abort()).To(Succeed())
+		Expect(outputFiles(dir)).To(BeEmpty())
+	})
+
+	Describe("with an existing output file", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(path, []byte("---\nkind: Old\n...\n"), 0o600)).To(Succeed())
+		})
+		It("refuses to overwrite it", func() {
+			err := newFileOutput(path, false, 0, 0).open()
+			Expect(err).To(MatchError(ContainSubstring("Output file already exists")))
+			Expect(outputFiles(dir)).To(ConsistOf("out.yaml"))
+		})
+		It("overwrites it with force", func() {
+			o := newFileOutput(path, false, 0, 0)
+			o.force = true
+			Expect(o.open()).To(Succeed())
+			writeResources(o, "Bucket")
+			Expect(documents(path)).To(Equal(1))
+			Expect(os.ReadFile(path)).To(ContainSubstring("kind: Old"))
+			Expect(o.Commit()).To(Succeed())
+			Expect(os.ReadFile(path)).NotTo(ContainSubstring("kind: Old"))
+		})
+		It("extends it with append", func() {
+			o := newFileOutput(path, false, 0, 0)
+			o.append = true
+			Expect(o.open()).To(Succeed())
+			writeResources(o, "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			Expect(documents(path)).To(Equal(2))
+			Expect(os.ReadFile(path)).To(HavePrefix("---\nkind: Old\n"))
+			By("keeping the mode of the existing file")
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o600)))
+		})
+		It("refuses to write split files over existing ones up front", func() {
+			existing := filepath.Join(dir, "out-bucket.yaml")
+			Expect(os.WriteFile(existing, []byte("---\nkind: Old\n...\n"), 0o600)).To(Succeed())
+			err := newFileOutput(path, true, 0, 0).open()
+			Expect(err).To(MatchError(ContainSubstring("Output file already exists")))
+			Expect(err.Attrs()).To(Equal([]any{"output", existing}))
+		})
+		It("ignores the files that the split output does not write", func() {
+			o := newFileOutput(path, false, 2, 0)
+			Expect(o.open()).To(Succeed())
+			writeResources(o, "Bucket")
+			Expect(o.Commit()).To(Succeed())
+			Expect(outputFiles(dir)).To(ConsistOf("out.yaml", "out-0001.yaml"))
+		})
+		It("rejects force together with append", func() {
+			setParam(OutputParam, path)
+			setParam(ForceParam, true)
+			setParam(AppendParam, true)
+			_, err := openOutput(nil)
+			Expect(err).To(MatchError(ContainSubstring("cannot be used together")))
+		})
+	})
+
+	Describe("after a write error", func() {
+		var o *fileOutput
+		var p *pipeline
+		BeforeEach(func() {
+			o = newFileOutput(path, false, 0, 0)
+			Expect(o.open()).To(Succeed())
+			// the documents fill the write buffer, so the closed
+			// file is written at once
+			Expect(o.files[""].file.Close()).To(Succeed())
+			p = newTestPipeline(o)
+		})
+		It("rejects and counts every following document", func() {
+			for range 3 {
+				res := newResource("Bucket", "b")
+				res.Object["data"] = strings.Repeat("x", 8192)
+				p.process(res)
+			}
+			Expect(p.result.Resources).To(BeZero())
+			Expect(p.result.Errors).To(Equal(3))
+		})
+		It("discards the output on commit", func() {
+			res := newResource("Bucket", "b")
+			res.Object["data"] = strings.Repeat("x", 8192)
+			Expect(o.Write(res, yamlRenderer())).To(MatchError(ContainSubstring("cannot write YAML to output")))
+			Expect(o.Commit()).To(MatchError(ContainSubstring("output is discarded")))
+			Expect(outputFiles(dir)).To(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("openOutput", func() {
+	It("prints on the console without an output file", func() {
+		Expect(openOutput(nil)).To(Equal(consoleOutput{}))
+	})
+	It("requires an output file for splitting", func() {
+		setParam(SplitByParam, splitByKind)
+		_, err := openOutput(nil)
+		Expect(err).To(MatchError(ContainSubstring("requires an output file")))
+	})
+	It("rejects invalid split values", func() {
+		setParam(OutputParam, filepath.Join(GinkgoT().TempDir(), "out.yaml"))
+		setParam(SplitByParam, "name")
+		_, err := openOutput(nil)
+		Expect(err).To(MatchError(ContainSubstring("Invalid split-by value")))
+		setParam(SplitByParam, "")
+		setParam(SplitResourcesParam, -1)
+		_, err = openOutput(nil)
+		Expect(err).To(MatchError(ContainSubstring("Invalid split-resources value")))
+	})
+	It("creates the split files when the resources are written", func() {
+		dir := GinkgoT().TempDir()
+		setParam(OutputParam, filepath.Join(dir, "out.yaml"))
+		setParam(SplitByParam, splitByKind)
+		o, err := openOutput(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(outputFiles(dir)).To(BeEmpty())
+		writeResources(o, "User")
+		Expect(o.Commit()).To(Succeed())
+		Expect(outputFiles(dir)).To(ConsistOf("out-user.yaml"))
+	})
+})
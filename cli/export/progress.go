@@ -0,0 +1,260 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/term"
+)
+
+const (
+	progressBarWidth    = 30
+	progressRefreshRate = 100 * time.Millisecond
+	progressLogInterval = 10 * time.Second
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+// progressEvent reports that done resources of a kind are
+// processed. A total less than or equal to zero means that the total
+// number of resources is unknown.
+type progressEvent struct {
+	Kind  string
+	Done  int
+	Total int
+}
+
+func (ev progressEvent) finished() bool {
+	return ev.Total > 0 && ev.Done >= ev.Total
+}
+
+// progressDisplay shows the progress of an export run.
+type progressDisplay interface {
+	// update records the latest progress of a kind.
+	update(ev progressEvent)
+	// refresh shows the recorded progress.
+	refresh()
+	// finish shows the final progress.
+	finish()
+	// interval returns how often refresh shall be invoked.
+	interval() time.Duration
+}
+
+// progressState keeps the latest progress of each kind in the order
+// the kinds are first reported.
+type progressState struct {
+	kinds    []string
+	progress map[string]progressEvent
+}
+
+func newProgressState() progressState {
+	return progressState{
+		progress: map[string]progressEvent{},
+	}
+}
+
+func (s *progressState) update(ev progressEvent) {
+	if _, ok := s.progress[ev.Kind]; !ok {
+		s.kinds = append(s.kinds, ev.Kind)
+	}
+	s.progress[ev.Kind] = ev
+}
+
+// barDisplay renders a progress bar, or a spinner if the total is
+// unknown, for each kind on a terminal. The lines are redrawn in
+// place. The log lines written to the display are printed above the
+// progress bars.
+type barDisplay struct {
+	progressState
+	mu    sync.Mutex
+	w     io.Writer
+	frame int
+	lines int
+}
+
+var _ progressDisplay = &barDisplay{}
+
+func newBarDisplay(w io.Writer) *barDisplay {
+	return &barDisplay{
+		progressState: newProgressState(),
+		w:             w,
+	}
+}
+
+func (d *barDisplay) interval() time.Duration {
+	return progressRefreshRate
+}
+
+func (d *barDisplay) line(ev progressEvent, width int) string {
+	kind := fmt.Sprintf("%-*s", width, ev.Kind)
+	if ev.Total <= 0 {
+		return fmt.Sprintf("%s %s %d", spinnerFrames[d.frame%len(spinnerFrames)], kind, ev.Done)
+	}
+	filled := min(progressBarWidth*ev.Done/ev.Total, progressBarWidth)
+	bar := strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+	mark := " "
+	if ev.finished() {
+		mark = "✓"
+	}
+	return fmt.Sprintf("%s %s %s %d/%d", mark, kind, bar, ev.Done, ev.Total)
+}
+
+func (d *barDisplay) refresh() {
+	if len(d.kinds) == 0 {
+		return
+	}
+	width := 0
+	for _, kind := range d.kinds {
+		width = max(width, len(kind))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sb := &strings.Builder{}
+	d.clear(sb)
+	for _, kind := range d.kinds {
+		fmt.Fprintln(sb, d.line(d.progress[kind], width))
+	}
+	d.lines = len(d.kinds)
+	d.frame++
+	fmt.Fprint(d.w, sb.String())
+}
+
+// clear writes the escape sequence erasing the previous rendering to
+// sb: it moves the cursor to the first line of the rendering and
+// clears the rest of the screen.
+func (d *barDisplay) clear(sb *strings.Builder) {
+	if d.lines > 0 {
+		fmt.Fprintf(sb, "\x1b[%dA\x1b[J", d.lines)
+	}
+	d.lines = 0
+}
+
+// Write erases the progress bars and writes p, so that the log lines
+// are not overwritten. The bars are redrawn below p at the next
+// refresh.
+func (d *barDisplay) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	sb := &strings.Builder{}
+	d.clear(sb)
+	if _, err := io.WriteString(d.w, sb.String()); err != nil {
+		return 0, err
+	}
+	return d.w.Write(p)
+}
+
+func (d *barDisplay) finish() {
+	d.refresh()
+}
+
+// logDisplay logs the progress of the kinds that have changed since
+// the last log lines. It is used when STDERR is not a terminal.
+type logDisplay struct {
+	progressState
+	logger  *slog.Logger
+	changed map[string]bool
+}
+
+var _ progressDisplay = &logDisplay{}
+
+func newLogDisplay(logger *slog.Logger) *logDisplay {
+	return &logDisplay{
+		progressState: newProgressState(),
+		logger:        logger,
+		changed:       map[string]bool{},
+	}
+}
+
+func (d *logDisplay) interval() time.Duration {
+	return progressLogInterval
+}
+
+func (d *logDisplay) update(ev progressEvent) {
+	d.progressState.update(ev)
+	d.changed[ev.Kind] = true
+}
+
+func (d *logDisplay) refresh() {
+	for _, kind := range d.kinds {
+		if !d.changed[kind] {
+			continue
+		}
+		ev := d.progress[kind]
+		if ev.Total > 0 {
+			d.logger.Info("Export progress", "kind", kind, "done", ev.Done, "total", ev.Total)
+		} else {
+			d.logger.Info("Export progress", "kind", kind, "done", ev.Done)
+		}
+	}
+	clear(d.changed)
+}
+
+func (d *logDisplay) finish() {
+	d.refresh()
+}
+
+// isTerminal reports whether f is connected to a terminal.
+func isTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
+
+// drawsBars reports whether the progress of the export into out is
+// drawn as progress bars. STDERR must be a terminal that does not
+// show the resources printed on the console as well, because the bars
+// would erase the last printed lines when they are redrawn.
+func drawsBars(out output, stdoutTerminal, stderrTerminal bool) bool {
+	if !stderrTerminal {
+		return false
+	}
+	_, console := out.(consoleOutput)
+	return !console || !stdoutTerminal
+}
+
+// newProgressDisplay returns the progressDisplay configured by the
+// ProgressParam for the export into out. The progress is shown on
+// STDERR, apart from the resources printed on STDOUT: as progress
+// bars if drawsBars permits, or logged with logger otherwise.
+func newProgressDisplay(logger *slog.Logger, out output) progressDisplay {
+	if !ProgressParam.Value() {
+		return nil
+	}
+	if drawsBars(out, isTerminal(os.Stdout), isTerminal(os.Stderr)) {
+		return newBarDisplay(os.Stderr)
+	}
+	return newLogDisplay(logger)
+}
+
+func showProgress(ctx context.Context, wg *sync.WaitGroup, display progressDisplay, events *eventLog, progressChan <-chan progressEvent) {
+	defer wg.Done()
+	var tick <-chan time.Time
+	if display != nil {
+		ticker := time.NewTicker(display.interval())
+		defer ticker.Stop()
+		tick = ticker.C
+		defer display.finish()
+	}
+	for {
+		select {
+		case ev, ok := <-progressChan:
+			if !ok {
+				// progress channel is closed
+				return
+			}
+			events.progress(ev)
+			if display != nil {
+				display.update(ev)
+			}
+		case <-tick:
+			display.refresh()
+		case <-ctx.Done():
+			// execution is cancelled
+			return
+		}
+	}
+}
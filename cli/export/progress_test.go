@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The progress bars", func() {
+	var buf *bytes.Buffer
+	var d *barDisplay
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		d = newBarDisplay(buf)
+	})
+
+	It("draw nothing before any progress is reported", func() {
+		d.refresh()
+		Expect(buf.String()).To(BeEmpty())
+	})
+
+	It("draw a line per kind in the order the kinds are reported", func() {
+		d.update(progressEvent{Kind: "Bucket", Done: 1, Total: 4})
+		d.update(progressEvent{Kind: "DNSZone", Done: 2, Total: 2})
+		d.update(progressEvent{Kind: "Bucket", Done: 2, Total: 4})
+		d.refresh()
+		Expect(strings.Split(buf.String(), "\n")).To(Equal([]string{
+			"  Bucket  " + strings.Repeat("█", 15) + strings.Repeat("░", 15) + " 2/4",
+			"✓ DNSZone " + strings.Repeat("█", 30) + " 2/2",
+			"",
+		}))
+	})
+
+	It("draw a spinner if the total is unknown", func() {
+		d.update(progressEvent{Kind: "Bucket", Done: 3})
+		d.refresh()
+		Expect(buf.String()).To(Equal("⠋ Bucket 3\n"))
+		buf.Reset()
+		d.refresh()
+		Expect(buf.String()).To(HaveSuffix("⠙ Bucket 3\n"))
+	})
+
+	It("redraw the previous lines in place", func() {
+		d.update(progressEvent{Kind: "Bucket", Done: 1, Total: 2})
+		d.update(progressEvent{Kind: "DNSZone", Done: 1})
+		d.refresh()
+		buf.Reset()
+		d.refresh()
+		Expect(buf.String()).To(HavePrefix("\x1b[2A\x1b[J"))
+	})
+
+	It("print the log lines above the bars", func() {
+		d.update(progressEvent{Kind: "Bucket", Done: 1, Total: 2})
+		d.refresh()
+		buf.Reset()
+		logger := newLogger(d)
+		logger.Info("Slow API")
+		Expect(buf.String()).To(HavePrefix("\x1b[1A\x1b[J"))
+		Expect(buf.String()).To(ContainSubstring("Slow API"))
+		buf.Reset()
+		d.refresh()
+		// the bars are drawn below the log line
+		Expect(buf.String()).NotTo(ContainSubstring("\x1b["))
+		Expect(buf.String()).To(ContainSubstring("1/2"))
+	})
+})
+
+var _ = Describe("The progress log", func() {
+	var buf *bytes.Buffer
+	var d *logDisplay
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		d = newLogDisplay(newTextLogger(buf))
+	})
+
+	It("logs the kinds that have changed since the last log lines", func() {
+		d.update(progressEvent{Kind: "Bucket", Done: 1, Total: 4})
+		d.update(progressEvent{Kind: "DNSZone", Done: 7})
+		d.refresh()
+		Expect(buf.String()).To(Equal(
+			"level=INFO msg=\"Export progress\" kind=Bucket done=1 total=4\n" +
+				"level=INFO msg=\"Export progress\" kind=DNSZone done=7\n"))
+		buf.Reset()
+		d.update(progressEvent{Kind: "Bucket", Done: 2, Total: 4})
+		d.refresh()
+		Expect(buf.String()).To(Equal("level=INFO msg=\"Export progress\" kind=Bucket done=2 total=4\n"))
+		buf.Reset()
+		d.finish()
+		Expect(buf.String()).To(BeEmpty())
+	})
+})
+
+var _ = Describe("The progress display", func() {
+	It("is disabled by the progress parameter", func() {
+		setParam(ProgressParam, false)
+		Expect(newProgressDisplay(slog.Default(), consoleOutput{})).To(BeNil())
+	})
+
+	It("is logged if STDERR is not a terminal", func() {
+		if isTerminal(os.Stderr) {
+			Skip("STDERR is a terminal")
+		}
+		setParam(ProgressParam, true)
+		Expect(newProgressDisplay(slog.Default(), consoleOutput{})).To(BeAssignableToTypeOf(&logDisplay{}))
+	})
+
+	DescribeTable("draws bars only on a terminal that does not show the resources",
+		func(out output, stdoutTerminal, stderrTerminal, bars bool) {
+			Expect(drawsBars(out, stdoutTerminal, stderrTerminal)).To(Equal(bars))
+		},
+		Entry("file output on a terminal", &recordingOutput{}, true, true, true),
+		Entry("file output without a terminal", &recordingOutput{}, true, false, false),
+		Entry("console output on the same terminal", consoleOutput{}, true, true, false),
+		Entry("console output piped", consoleOutput{}, false, true, true),
+		Entry("console output without a terminal", consoleOutput{}, false, false, false),
+	)
+
+	It("records the progress in the event stream without a display", func() {
+		buf := &bytes.Buffer{}
+		p := newTestPipeline(&recordingOutput{})
+		p.events = newEventLog(buf)
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Progress("Bucket", 1, 2)
+			events.Progress("Bucket", 2, 2)
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		events := readEvents(buf)
+		Expect(eventTypes(events)).To(Equal([]eventType{eventRunStarted, eventProgress, eventProgress, eventRunFinished}))
+	})
+})
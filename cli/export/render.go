@@ -0,0 +1,194 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+)
+
+// renderFunc writes the representation of a resource to w. The
+// output flushes w, renderers must not flush it. A renderer writes
+// nothing if it fails, except when writing to w fails.
+type renderFunc func(w *bufio.Writer, res resource.Object) erratt.Error
+
+// objectContent returns the content of res as a JSON-compatible
+// map. Resources wrapped in a [yaml.ResourceWithComment] are
+// unwrapped.
+func objectContent(res resource.Object) (map[string]any, error) {
+	if r, ok := res.(*yaml.ResourceWithComment); ok {
+		res = r.Resource()
+	}
+	if u, ok := res.(runtime.Unstructured); ok {
+		return u.UnstructuredContent(), nil
+	}
+	return runtime.DefaultUnstructuredConverter.ToUnstructured(res)
+}
+
+// terminate appends a newline to s unless it is empty or already
+// ends with one, so that each resource starts on a new line.
+func terminate(s string) string {
+	if s == "" || strings.HasSuffix(s, "\n") {
+		return s
+	}
+	return s + "\n"
+}
+
+func templateRenderer(text string) (renderFunc, erratt.Error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, erratt.Errorf("Cannot parse output template: %w", err).With("output-template", text)
+	}
+	return func(w *bufio.Writer, res resource.Object) erratt.Error {
+		content, err := objectContent(res)
+		if err != nil {
+			return erratt.Errorf("cannot convert resource: %w", err)
+		}
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, content); err != nil {
+			return erratt.Errorf("cannot execute output template: %w", err)
+		}
+		return writeString(w, terminate(buf.String()))
+	}, nil
+}
+
+func jsonPathRenderer(text string) (renderFunc, erratt.Error) {
+	jp := jsonpath.New("output").AllowMissingKeys(true)
+	if err := jp.Parse(text); err != nil {
+		return nil, erratt.Errorf("Cannot parse JSONPath expression: %w", err).With("jsonpath", text)
+	}
+	return func(w *bufio.Writer, res resource.Object) erratt.Error {
+		content, err := objectContent(res)
+		if err != nil {
+			return erratt.Errorf("cannot convert resource: %w", err)
+		}
+		buf := &bytes.Buffer{}
+		if err := jp.Execute(buf, content); err != nil {
+			return erratt.Errorf("cannot execute JSONPath expression: %w", err)
+		}
+		return writeString(w, terminate(buf.String()))
+	}, nil
+}
+
+// writeString writes s to w.
+func writeString(w *bufio.Writer, s string) erratt.Error {
+	if _, err := w.WriteString(s); err != nil {
+		return erratt.Errorf("cannot write to output: %w", err)
+	}
+	return nil
+}
+
+// ndjsonRenderer renders each resource as a single line of JSON.
+// The comments of the resource are not rendered.
+func ndjsonRenderer() renderFunc {
+	return func(w *bufio.Writer, res resource.Object) erratt.Error {
+		content, err := objectContent(res)
+		if err != nil {
+			return erratt.Errorf("cannot convert resource: %w", err)
+		}
+		b, err := json.Marshal(content)
+		if err != nil {
+			return erratt.Errorf("cannot JSON-marshal resource: %w", err)
+		}
+		return writeString(w, string(b)+"\n")
+	}
+}
+
+func yamlRenderer(opts ...yaml.Option) renderFunc {
+	return func(w *bufio.Writer, res resource.Object) erratt.Error {
+		// The encoder uses w as its buffer, which is flushed by the
+		// output.
+		if err := yaml.NewEncoder(w, opts...).Encode(res); err != nil {
+			return erratt.Errorf("cannot YAML-marshal resource: %w", err)
+		}
+		return nil
+	}
+}
+
+func prettyYAMLRenderer(opts ...yaml.Option) renderFunc {
+	return func(w *bufio.Writer, res resource.Object) erratt.Error {
+		y, err := yaml.MarshalPretty(res, opts...)
+		if err != nil {
+			return erratt.Errorf("cannot YAML-marshal resource: %w", err)
+		}
+		return writeString(w, y)
+	}
+}
+
+// yamlOptions returns the YAML marshalling options configured by the
+// parameters of the export subcommand.
+func yamlOptions() []yaml.Option {
+	opts := []yaml.Option{}
+	if CanonicalOrderParam.Value() {
+		opts = append(opts, yaml.WithCanonicalOrder())
+	}
+	if LiteralBlocksParam.Value() {
+		opts = append(opts, yaml.WithLiteralBlocks())
+	}
+	if width := FoldWidthParam.Value(); width > 0 {
+		opts = append(opts, yaml.WithFoldWidth(width))
+	}
+	return opts
+}
+
+// useColor reports whether the YAML printed on the console is
+// syntax-highlighted, as configured by the ColorParam. In auto mode,
+// the output is highlighted if the standard output is a terminal, as
+// reported by terminal, and the NO_COLOR environment variable is not
+// set.
+func useColor(terminal bool) (bool, erratt.Error) {
+	switch color := ColorParam.Value(); color {
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	case colorAuto:
+		return os.Getenv("NO_COLOR") == "" && terminal, nil
+	default:
+		return false, erratt.New("Invalid color value", "color", color, "supported", []string{colorAuto, colorAlways, colorNever})
+	}
+}
+
+// newRenderer returns the renderFunc configured by the
+// OutputTemplateParam and the JSONPathParam. By default, resources
+// are rendered as YAML, syntax-highlighted when printed on the
+// console and colors are enabled.
+func newRenderer(out output) (renderFunc, erratt.Error) {
+	color, err := useColor(isTerminal(os.Stdout))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := OutputTemplateParam.Value()
+	jp := JSONPathParam.Value()
+	switch {
+	case tmpl != "" && jp != "":
+		return nil, erratt.New("The output-template and jsonpath options cannot be used together")
+	case tmpl != "":
+		return templateRenderer(tmpl)
+	case jp != "":
+		return jsonPathRenderer(jp)
+	}
+	if _, ok := out.(consoleOutput); ok && color {
+		style := ColorStyleParam.Value()
+		if err := yaml.ValidateStyle(style); err != nil {
+			return nil, erratt.Errorf("Invalid color style: %w", err).With("color-style", style)
+		}
+		return prettyYAMLRenderer(append(yamlOptions(), yaml.WithStyle(style))...), nil
+	}
+	return yamlRenderer(yamlOptions()...), nil
+}
@@ -0,0 +1,156 @@
+package export
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// render returns the representation of res written by fn.
+func render(fn renderFunc, res resource.Object) (string, error) {
+	sb := &strings.Builder{}
+	w := bufio.NewWriter(sb)
+	if err := fn(w, res); err != nil {
+		return "", err
+	}
+	ExpectWithOffset(1, w.Flush()).To(Succeed())
+	return sb.String(), nil
+}
+
+var _ = Describe("Rendering resources", func() {
+	var res *yaml.ResourceWithComment
+	BeforeEach(func() {
+		bucket := newResource("Bucket", "logs")
+		bucket.Object["spec"] = map[string]any{"region": "eu10"}
+		res = yaml.NewResourceWithComment(bucket)
+		res.SetComment("generated")
+		res.SetCommentOut(false)
+	})
+
+	It("renders YAML like Marshal", func() {
+		y, err := yaml.Marshal(res, yaml.WithCanonicalOrder())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(render(yamlRenderer(yaml.WithCanonicalOrder()), res)).To(Equal(y))
+	})
+
+	Describe("with a Go template", func() {
+		It("renders each resource on a new line", func() {
+			fn, err := templateRenderer("{{.metadata.name}} {{.spec.region}}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(render(fn, res)).To(Equal("logs eu10\n"))
+		})
+		It("rejects an invalid template", func() {
+			_, err := templateRenderer("{{.metadata.name")
+			Expect(err).To(MatchError(ContainSubstring("Cannot parse output template")))
+		})
+		It("reports a failing template without writing", func() {
+			fn, err := templateRenderer("{{index .spec 1}}")
+			Expect(err).NotTo(HaveOccurred())
+			_, renderErr := render(fn, res)
+			Expect(renderErr).To(MatchError(ContainSubstring("cannot execute output template")))
+		})
+	})
+
+	Describe("with a JSONPath expression", func() {
+		It("renders each resource on a new line", func() {
+			fn, err := jsonPathRenderer("{.kind}/{.metadata.name}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(render(fn, res)).To(Equal("Bucket/logs\n"))
+		})
+		It("renders missing fields as empty", func() {
+			fn, err := jsonPathRenderer("{.spec.missing}")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(render(fn, res)).To(BeEmpty())
+		})
+		It("rejects an invalid expression", func() {
+			_, err := jsonPathRenderer("{.metadata.name")
+			Expect(err).To(MatchError(ContainSubstring("Cannot parse JSONPath expression")))
+		})
+	})
+
+	It("renders NDJSON without the comments", func() {
+		Expect(render(ndjsonRenderer(), res)).To(Equal(
+			`{"apiVersion":"test.example.com/v1","kind":"Bucket","metadata":{"name":"logs"},"spec":{"region":"eu10"}}` + "\n"))
+	})
+})
+
+var _ = Describe("newRenderer", func() {
+	It("rejects a template together with a JSONPath expression", func() {
+		setParam(OutputTemplateParam, "{{.kind}}")
+		setParam(JSONPathParam, "{.kind}")
+		_, err := newRenderer(consoleOutput{})
+		Expect(err).To(MatchError(ContainSubstring("cannot be used together")))
+	})
+	It("rejects an invalid color value", func() {
+		setParam(ColorParam, "sometimes")
+		_, err := newRenderer(consoleOutput{})
+		Expect(err).To(MatchError(ContainSubstring("Invalid color value")))
+	})
+	It("highlights the console output only with colors", func() {
+		res := newResource("Bucket", "logs")
+		plain, err := yaml.Marshal(res)
+		Expect(err).NotTo(HaveOccurred())
+
+		setParam(ColorParam, colorNever)
+		fn, err := newRenderer(consoleOutput{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(render(fn, res)).To(Equal(plain))
+
+		setParam(ColorParam, colorAlways)
+		fn, err = newRenderer(consoleOutput{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(render(fn, res)).NotTo(Equal(plain))
+		By("leaving the file output plain")
+		fn, err = newRenderer(&fileOutput{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(render(fn, res)).To(Equal(plain))
+	})
+	It("rejects an unknown color style", func() {
+		setParam(ColorParam, colorAlways)
+		setParam(ColorStyleParam, "no-such-style")
+		_, err := newRenderer(consoleOutput{})
+		Expect(err).To(MatchError(ContainSubstring("Invalid color style")))
+		By("ignoring the style without colors")
+		setParam(ColorParam, colorNever)
+		Expect(newRenderer(consoleOutput{})).NotTo(BeNil())
+	})
+	It("accepts a standard style and a style file", func() {
+		setParam(ColorParam, colorAlways)
+		setParam(ColorStyleParam, "light")
+		Expect(newRenderer(consoleOutput{})).NotTo(BeNil())
+		styleFile := filepath.Join(GinkgoT().TempDir(), "style.json")
+		Expect(os.WriteFile(styleFile, []byte(`{"code_block": {"color": "203"}}`), 0o600)).To(Succeed())
+		setParam(ColorStyleParam, styleFile)
+		Expect(newRenderer(consoleOutput{})).NotTo(BeNil())
+	})
+	It("applies the YAML options", func() {
+		setParam(CanonicalOrderParam, true)
+		res := newResource("Bucket", "logs")
+		res.Object["data"] = "x"
+		fn, err := newRenderer(&fileOutput{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(render(fn, res)).To(HavePrefix("---\napiVersion: test.example.com/v1\nkind: Bucket\n"))
+	})
+})
+
+var _ = DescribeTable("useColor",
+	func(color, noColor string, terminal, highlighted bool) {
+		setParam(ColorParam, color)
+		GinkgoT().Setenv("NO_COLOR", noColor)
+		Expect(useColor(terminal)).To(Equal(highlighted))
+	},
+	Entry("auto on a terminal", colorAuto, "", true, true),
+	Entry("auto without a terminal", colorAuto, "", false, false),
+	Entry("auto with NO_COLOR", colorAuto, "1", true, false),
+	Entry("always without a terminal", colorAlways, "", false, true),
+	Entry("always with NO_COLOR", colorAlways, "1", true, true),
+	Entry("never on a terminal", colorNever, "", true, false),
+)
@@ -0,0 +1,55 @@
+package export
+
+import (
+	"fmt"
+
+	"github.com/SAP/xp-clifford/erratt"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AddToScheme registers the Go types of typed resources, such as
+// the managed resources of a Crossplane provider, for the export
+// subcommand. It accepts the AddToScheme functions generated for
+// the API packages:
+//
+//	export.AddToScheme(v1alpha1.AddToScheme)
+//
+// The apiVersion and kind of the exported typed resources are set
+// based on the registered types. Once a type is registered, typed
+// resources of unknown types are rejected with a warning.
+func AddToScheme(addToScheme ...func(*runtime.Scheme) error) error {
+	if exportCmd.scheme == nil {
+		exportCmd.scheme = runtime.NewScheme()
+	}
+	for _, fn := range addToScheme {
+		if err := fn(exportCmd.scheme); err != nil {
+			return erratt.Errorf("Cannot register types: %w", err)
+		}
+	}
+	return nil
+}
+
+// setGroupVersionKind sets the GroupVersionKind of the typed
+// resource res based on scheme, unless it is already set. It returns
+// an error if the type or the GroupVersionKind of res is not
+// registered in scheme. Unstructured resources are accepted as is.
+func setGroupVersionKind(scheme *runtime.Scheme, res resource.Object) erratt.Error {
+	obj := unwrapResource(res)
+	if _, ok := obj.(runtime.Unstructured); ok || scheme == nil {
+		return nil
+	}
+	if gvk := obj.GetObjectKind().GroupVersionKind(); !gvk.Empty() {
+		if !scheme.Recognizes(gvk) {
+			return erratt.New("Unknown GroupVersionKind", "gvk", gvk.String())
+		}
+		return nil
+	}
+	gvks, _, err := scheme.ObjectKinds(obj)
+	if err != nil {
+		return erratt.Errorf("Cannot determine GroupVersionKind: %w", err).With("type", fmt.Sprintf("%T", obj))
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvks[0])
+	return nil
+}
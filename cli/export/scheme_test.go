@@ -0,0 +1,120 @@
+package export
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var testGroupVersion = schema.GroupVersion{Group: "test.example.com", Version: "v1"}
+
+// testBucket is a typed resource registered by addTestTypes.
+type testBucket struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata"`
+}
+
+func (b *testBucket) DeepCopyObject() runtime.Object {
+	c := *b
+	b.ObjectMeta.DeepCopyInto(&c.ObjectMeta)
+	return &c
+}
+
+// testUser is a typed resource that is not registered.
+type testUser struct {
+	testBucket
+}
+
+func (u *testUser) DeepCopyObject() runtime.Object {
+	return &testUser{testBucket: *u.testBucket.DeepCopyObject().(*testBucket)}
+}
+
+func addTestTypes(scheme *runtime.Scheme) error {
+	scheme.AddKnownTypes(testGroupVersion, &testBucket{})
+	return nil
+}
+
+func newTestBucket(name string) *testBucket {
+	return &testBucket{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+var _ = Describe("AddToScheme", func() {
+	BeforeEach(func() {
+		scheme := exportCmd.scheme
+		DeferCleanup(func() {
+			exportCmd.scheme = scheme
+		})
+		exportCmd.scheme = nil
+	})
+
+	It("registers the types for the export subcommand", func() {
+		Expect(AddToScheme(addTestTypes)).To(Succeed())
+		Expect(exportCmd.scheme.Recognizes(testGroupVersion.WithKind("testBucket"))).To(BeTrue())
+	})
+
+	It("returns the error of a registration", func() {
+		err := AddToScheme(func(*runtime.Scheme) error {
+			return errors.New("conflicting type")
+		})
+		Expect(err).To(MatchError(ContainSubstring("conflicting type")))
+	})
+})
+
+var _ = Describe("setGroupVersionKind", func() {
+	var scheme *runtime.Scheme
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(addTestTypes(scheme)).To(Succeed())
+	})
+
+	It("sets the GroupVersionKind of a registered type", func() {
+		res := newTestBucket("logs")
+		Expect(setGroupVersionKind(scheme, res)).To(Succeed())
+		Expect(res.GroupVersionKind()).To(Equal(testGroupVersion.WithKind("testBucket")))
+	})
+
+	It("sets the GroupVersionKind of a wrapped resource", func() {
+		res := newTestBucket("logs")
+		Expect(setGroupVersionKind(scheme, yaml.NewResourceWithComment(res))).To(Succeed())
+		Expect(res.Kind).To(Equal("testBucket"))
+	})
+
+	It("rejects an unregistered type", func() {
+		err := setGroupVersionKind(scheme, &testUser{})
+		Expect(err).To(MatchError(ContainSubstring("Cannot determine GroupVersionKind")))
+	})
+
+	It("rejects an unregistered GroupVersionKind", func() {
+		res := newTestBucket("logs")
+		res.SetGroupVersionKind(testGroupVersion.WithKind("Bucket"))
+		Expect(setGroupVersionKind(scheme, res)).To(MatchError("Unknown GroupVersionKind"))
+	})
+
+	It("accepts unstructured resources and any resource without a scheme", func() {
+		Expect(setGroupVersionKind(scheme, newResource("Unknown", "logs"))).To(Succeed())
+		Expect(setGroupVersionKind(nil, &testUser{})).To(Succeed())
+	})
+
+	It("rejects the unregistered resources in the pipeline", func() {
+		out := &recordingOutput{}
+		p := newTestPipeline(out)
+		p.scheme = scheme
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newTestBucket("logs"))
+			events.Resource(&testUser{})
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.resources).To(HaveLen(1))
+		Expect(out.resources[0].GetObjectKind().GroupVersionKind().Kind).To(Equal("testBucket"))
+		Expect(p.result.Warnings).To(Equal(1))
+	})
+})
@@ -0,0 +1,468 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SAP/xp-clifford/cli"
+	"github.com/SAP/xp-clifford/cli/configparam"
+	"github.com/SAP/xp-clifford/erratt"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"github.com/spf13/viper"
+)
+
+const (
+	serveFormatYAML   = "yaml"
+	serveFormatNDJSON = "ndjson"
+	// statusTrailer is the HTTP trailer that holds the status of the
+	// export run, as its result is known only after the resources are
+	// streamed.
+	statusTrailer = "X-Export-Status"
+
+	runSucceeded = "succeeded"
+	runPartial   = "partial"
+	runFailed    = "failed"
+
+	serveReadHeaderTimeout = 10 * time.Second
+	serveShutdownTimeout   = 30 * time.Second
+)
+
+func init() {
+	cli.RegisterSubCommand(serveCmd)
+}
+
+var ServeAddressParam = configparam.String("address", "address on which the HTTP API is served").
+	WithFlagName("address").
+	WithEnvVarName("SERVE_ADDRESS").
+	WithDefaultValue("127.0.0.1:8080")
+
+// requestParams are the config parameters of the export subcommand
+// that can be set per request. The parameters added with
+// AddConfigParams can be set as well.
+var requestParams = configparam.ParamList{
+	ResourceKindParam,
+	FilterParam,
+	NormalizeParam,
+	RemoveFieldsParam,
+	CanonicalOrderParam,
+	LiteralBlocksParam,
+	FoldWidthParam,
+}
+
+// serveParams are the config parameters of the export subcommand
+// that apply to the served exports, besides the request parameters.
+// The output, Git, watch and encryption parameters do not apply.
+var serveParams = configparam.ParamList{
+	ValidateCRDsParam,
+	EventsFileParam,
+	EventsFormatParam,
+	AggregateWarningsParam,
+	WarningLimitParam,
+	WarningGroupByParam,
+}
+
+// serveSubCommand serves the export subcommand over a local HTTP API.
+type serveSubCommand struct {
+	export *exportSubCommand
+}
+
+var (
+	_        cli.SubCommand = &serveSubCommand{}
+	serveCmd                = &serveSubCommand{export: exportCmd}
+)
+
+func (c *serveSubCommand) GetName() string {
+	return "serve"
+}
+
+func (c *serveSubCommand) GetShort() string {
+	return fmt.Sprintf("Serve the export of %s resources over HTTP", cli.Configuration.ObservedSystem)
+}
+
+func (c *serveSubCommand) GetLong() string {
+	return fmt.Sprintf("Serve the export of %s resources over a local HTTP API. The config parameters are the defaults of the export requests", cli.Configuration.ObservedSystem)
+}
+
+func (c *serveSubCommand) GetConfigParams() configparam.ParamList {
+	params := configparam.ParamList{ServeAddressParam}
+	params = append(params, requestParams...)
+	params = append(params, serveParams...)
+	return append(params, c.export.addedParams...)
+}
+
+func (c *serveSubCommand) MustIgnoreConfigFile() bool {
+	return false
+}
+
+func (c *serveSubCommand) GetRun() func(context.Context) error {
+	return func(ctx context.Context) error {
+		// The served exports are streamed, they cannot be sealed
+		// like the output files.
+		if fields := append(slices.Clone(c.export.encryptedFields), EncryptFieldsParam.Value()...); len(fields) > 0 {
+			return erratt.New("Exports with encrypted fields cannot be served", "fields", fields)
+		}
+		events, err := openEventLog()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := events.Close(); err != nil {
+				erratt.Slog(erratt.Errorf("Cannot close events file: %w", err))
+			}
+		}()
+		address := ServeAddressParam.Value()
+		listener, lErr := net.Listen("tcp", address)
+		if lErr != nil {
+			return erratt.Errorf("Cannot listen on address: %w", lErr).With("address", address)
+		}
+		srv := &http.Server{
+			Handler:           newServer(c.export, events).handler(),
+			ReadHeaderTimeout: serveReadHeaderTimeout,
+			// the running exports are interrupted with the server
+			BaseContext: func(net.Listener) context.Context { return ctx },
+		}
+		serveErr := make(chan error, 1)
+		go func() {
+			serveErr <- srv.Serve(listener)
+		}()
+		slog.Info("Serving exports over HTTP", "address", listener.Addr().String())
+		select {
+		case err := <-serveErr:
+			return erratt.Errorf("HTTP server failed: %w", err).With("address", address)
+		case <-ctx.Done():
+		}
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), serveShutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return erratt.Errorf("Cannot shut down HTTP server: %w", err)
+		}
+		slog.Info("HTTP server is stopped")
+		return nil
+	}
+}
+
+// runSummary is the summary of an export run served over HTTP.
+type runSummary struct {
+	Started   time.Time      `json:"started"`
+	Finished  time.Time      `json:"finished"`
+	Format    string         `json:"format"`
+	Status    string         `json:"status"`
+	Kinds     map[string]int `json:"kinds"`
+	Resources int            `json:"resources"`
+	Infos     int            `json:"infos"`
+	Warnings  int            `json:"warnings"`
+	Errors    int            `json:"errors"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// server handles the requests of the HTTP API. The export runs are
+// serialized, as the config parameters are global.
+type server struct {
+	export *exportSubCommand
+	events *eventLog
+	// sem is held by the running export.
+	sem  chan struct{}
+	mu   sync.Mutex
+	last *runSummary
+}
+
+func newServer(export *exportSubCommand, events *eventLog) *server {
+	return &server{
+		export: export,
+		events: events,
+		sem:    make(chan struct{}, 1),
+	}
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /export", s.handleExport)
+	mux.HandleFunc("POST /export", s.handleExport)
+	mux.HandleFunc("GET /summary", s.handleSummary)
+	return mux
+}
+
+// handleExport runs the export and streams the exported resources in
+// the requested format. The config parameters are overridden by the
+// request parameters for the time of the run.
+func (s *server) handleExport(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, erratt.Errorf("Cannot parse request parameters: %w", err))
+		return
+	}
+	values := maps.Clone(r.Form)
+	format := values.Get("format")
+	values.Del("format")
+	var render renderFunc
+	contentType := ""
+	switch format {
+	case "", serveFormatYAML:
+		format = serveFormatYAML
+		contentType = "application/yaml"
+	case serveFormatNDJSON:
+		render = ndjsonRenderer()
+		contentType = "application/x-ndjson"
+	default:
+		writeError(w, http.StatusBadRequest, erratt.New("Invalid format value", "format", format, "supported", []string{serveFormatYAML, serveFormatNDJSON}))
+		return
+	}
+
+	select {
+	case s.sem <- struct{}{}:
+		defer func() { <-s.sem }()
+	case <-r.Context().Done():
+		return
+	}
+	restore, err := s.override(values)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	defer restore()
+	if render == nil {
+		// the YAML options can be set per request
+		render = yamlRenderer(yamlOptions()...)
+	}
+	p, err := newStages(s.export, s.events)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	out := newHTTPOutput(w)
+	p.out = out
+	p.render = render
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Trailer", statusTrailer)
+	summary := &runSummary{Started: time.Now(), Format: format}
+	runErr := s.export.run(r.Context(), p, nil)
+	summary.finish(p, runErr)
+	s.mu.Lock()
+	s.last = summary
+	s.mu.Unlock()
+	if summary.Status == runFailed && !out.written {
+		writeError(w, http.StatusInternalServerError, runErr)
+		return
+	}
+	w.Header().Set(statusTrailer, summary.Status)
+}
+
+// handleSummary responds with the summary of the last export run.
+func (s *server) handleSummary(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	summary := s.last
+	s.mu.Unlock()
+	if summary == nil {
+		writeError(w, http.StatusNotFound, erratt.New("No export has been run yet"))
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// finish records the result of the run of p in the summary.
+func (summary *runSummary) finish(p *pipeline, err error) {
+	summary.Finished = time.Now()
+	summary.Kinds = p.events.kindCounts()
+	summary.Resources = p.result.Resources
+	summary.Infos = p.result.Infos
+	summary.Warnings = p.result.Warnings
+	summary.Errors = p.result.Errors
+	switch {
+	case err == nil:
+		summary.Status = runSucceeded
+	case cli.ExitCode(err) == ExitCodePartialFailure:
+		summary.Status = runPartial
+	default:
+		summary.Status = runFailed
+	}
+	if err != nil {
+		summary.Error = err.Error()
+	}
+}
+
+// override sets the config parameters named by the flag names in
+// values. The returned function restores the previous values, and
+// unsets the parameters that were not set before. If no
+// kind is requested or configured, all exportable kinds are
+// exported.
+func (s *server) override(values url.Values) (func(), erratt.Error) {
+	params := map[string]configparam.ConfigParam{}
+	for _, param := range append(slices.Clone(requestParams), s.export.addedParams...) {
+		if p, ok := param.(interface{ GetFlagName() string }); ok {
+			params[p.GetFlagName()] = param
+		}
+	}
+	type setting struct {
+		name        string
+		value, prev any
+	}
+	var settings []setting
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		param, ok := params[key]
+		if !ok {
+			return nil, erratt.New("Unsupported request parameter", "parameter", key, "supported", slices.Sorted(maps.Keys(params)))
+		}
+		value, prev, err := parseParamValue(param, values[key])
+		if err != nil {
+			return nil, erratt.Errorf("Invalid request parameter value: %w", err).With("parameter", key)
+		}
+		if !viper.IsSet(param.GetName()) {
+			// restoring the default would mark the parameter as set
+			prev = nil
+		}
+		settings = append(settings, setting{param.GetName(), value, prev})
+	}
+	if _, ok := values[ResourceKindParam.FlagName]; !ok && !ResourceKindParam.IsSet() && len(s.export.exportableResourceKinds) > 0 {
+		settings = append(settings, setting{ResourceKindParam.GetName(), s.export.exportableResourceKinds, nil})
+	}
+	for _, st := range settings {
+		viper.Set(st.name, st.value)
+	}
+	return func() {
+		for _, st := range slices.Backward(settings) {
+			viper.Set(st.name, st.prev)
+		}
+	}, nil
+}
+
+// parseParamValue parses the request values of param. It returns the
+// parsed value and the current value of param. A boolean parameter
+// without value is true, list values may be separated by commas.
+func parseParamValue(param configparam.ConfigParam, values []string) (any, any, error) {
+	single := func() (string, error) {
+		if len(values) != 1 {
+			return "", errors.New("a single value is expected")
+		}
+		return values[0], nil
+	}
+	var list []string
+	for _, v := range values {
+		for item := range strings.SplitSeq(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	switch p := param.(type) {
+	case *configparam.BoolParam:
+		v, err := single()
+		if err != nil {
+			return nil, nil, err
+		}
+		if v == "" {
+			return true, p.Value(), nil
+		}
+		b, err := strconv.ParseBool(v)
+		return b, p.Value(), err
+	case *configparam.IntParam:
+		v, err := single()
+		if err != nil {
+			return nil, nil, err
+		}
+		i, err := strconv.Atoi(v)
+		return i, p.Value(), err
+	case *configparam.FloatParam:
+		v, err := single()
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		return f, p.Value(), err
+	case *configparam.DurationParam:
+		v, err := single()
+		if err != nil {
+			return nil, nil, err
+		}
+		d, err := time.ParseDuration(v)
+		return d, p.Value(), err
+	case *configparam.StringParam:
+		v, err := single()
+		return v, p.Value(), err
+	case *configparam.StringSliceParam:
+		return list, p.Value(), nil
+	case *configparam.IntSliceParam:
+		ints := make([]int, 0, len(list))
+		for _, item := range list {
+			i, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			ints = append(ints, i)
+		}
+		return ints, p.Value(), nil
+	}
+	return nil, nil, fmt.Errorf("unsupported parameter type %T", param)
+}
+
+// writeJSON writes v as the JSON body of the response.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	// The client may be gone, the error is not actionable.
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err and its attributes as the JSON body of the
+// response.
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Del("Trailer")
+	body := map[string]any{"error": err.Error()}
+	if attrs := errorAttributes(err); attrs != nil {
+		body["attributes"] = attrs
+	}
+	writeJSON(w, status, body)
+}
+
+// httpOutput streams the documents in the body of an HTTP response.
+// Each document is flushed to the client when it is written.
+type httpOutput struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	written bool
+}
+
+var _ output = &httpOutput{}
+
+func newHTTPOutput(w http.ResponseWriter) *httpOutput {
+	return &httpOutput{
+		w:  w,
+		rc: http.NewResponseController(w),
+	}
+}
+
+func (o *httpOutput) Write(res resource.Object, render renderFunc) error {
+	w := bufio.NewWriter(o.w)
+	if err := render(w, res); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return erratt.Errorf("cannot write response: %w", err)
+	}
+	o.written = true
+	if err := o.rc.Flush(); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return erratt.Errorf("cannot flush response: %w", err)
+	}
+	return nil
+}
+
+func (o *httpOutput) Commit() error {
+	return nil
+}
+
+func (o *httpOutput) Abort() error {
+	return nil
+}
@@ -0,0 +1,117 @@
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("The served exports", func() {
+	var srv *httptest.Server
+	BeforeEach(func() {
+		c := &exportSubCommand{runCommand: func(_ context.Context, events EventHandler) error {
+			events.Resource(newResource("Bucket", "prod-logs"))
+			events.Resource(newResource("Bucket", "dev-logs"))
+			events.Resource(newResource("User", "prod-admin"))
+			return nil
+		}}
+		srv = httptest.NewServer(newServer(c, newEventLog(nil)).handler())
+		DeferCleanup(srv.Close)
+	})
+
+	// get requests the path with the query parameters.
+	get := func(path string, query url.Values) *http.Response {
+		resp, err := http.Get(srv.URL + path + "?" + query.Encode())
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		DeferCleanup(resp.Body.Close)
+		return resp
+	}
+
+	It("streams the resources that match the filter of the request", func() {
+		resp := get("/export", url.Values{
+			"format": {"ndjson"},
+			"filter": {`object.metadata.name.startsWith("prod-")`},
+		})
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("application/x-ndjson"))
+		names := []string{}
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			obj := map[string]any{}
+			Expect(json.Unmarshal(scanner.Bytes(), &obj)).To(Succeed())
+			names = append(names, obj["metadata"].(map[string]any)["name"].(string))
+		}
+		Expect(scanner.Err()).NotTo(HaveOccurred())
+		Expect(names).To(Equal([]string{"prod-logs", "prod-admin"}))
+		Expect(resp.Trailer.Get(statusTrailer)).To(Equal(runSucceeded))
+	})
+
+	It("records the summary of the last run", func() {
+		Expect(get("/summary", nil).StatusCode).To(Equal(http.StatusNotFound))
+		get("/export", url.Values{"kind": {"Bucket"}})
+		resp := get("/summary", nil)
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		summary := runSummary{}
+		Expect(json.NewDecoder(resp.Body).Decode(&summary)).To(Succeed())
+		Expect(summary.Status).To(Equal(runSucceeded))
+		Expect(summary.Resources).To(Equal(3))
+	})
+
+	It("exports all exportable kinds again after a request for a kind", func() {
+		var kinds [][]string
+		c := &exportSubCommand{
+			exportableResourceKinds: []string{"Bucket", "User"},
+			runCommand: func(_ context.Context, _ EventHandler) error {
+				kinds = append(kinds, ResourceKindParam.Value())
+				return nil
+			},
+		}
+		srv := httptest.NewServer(newServer(c, newEventLog(nil)).handler())
+		DeferCleanup(srv.Close)
+		for _, query := range []string{"?kind=Bucket", ""} {
+			resp, err := http.Get(srv.URL + "/export" + query)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resp.Body.Close()).To(Succeed())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		}
+		Expect(kinds).To(Equal([][]string{{"Bucket"}, {"Bucket", "User"}}))
+		Expect(ResourceKindParam.IsSet()).To(BeFalse())
+	})
+
+	It("rejects the parameters that do not apply to a request", func() {
+		resp := get("/export", url.Values{"output": {"out.yaml"}})
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("rejects an invalid filter", func() {
+		resp := get("/export", url.Values{"filter": {"object."}})
+		Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
+
+var _ = Describe("The serve subcommand", func() {
+	It("has only the parameters that apply to the served exports", func() {
+		params := serveCmd.GetConfigParams()
+		Expect(params).To(ContainElements(ServeAddressParam, FilterParam, ValidateCRDsParam))
+		for _, param := range []any{OutputParam, GitRepoParam, WatchParam, SplitByParam, EncryptFieldsParam, ForceParam, ProgressParam} {
+			Expect(params).NotTo(ContainElement(param))
+		}
+	})
+
+	It("refuses to serve exports with encrypted fields", func() {
+		c := &serveSubCommand{export: &exportSubCommand{encryptedFields: []string{"password"}}}
+		Expect(c.GetRun()(context.Background())).To(MatchError("Exports with encrypted fields cannot be served"))
+	})
+
+	It("refuses to serve exports with the fields of the encrypt-fields parameter", func() {
+		setParam(EncryptFieldsParam, []string{"token"})
+		c := &serveSubCommand{export: &exportSubCommand{}}
+		Expect(c.GetRun()(context.Background())).To(MatchError("Exports with encrypted fields cannot be served"))
+	})
+})
@@ -0,0 +1,78 @@
+package export
+
+import (
+	"log/slog"
+
+	"github.com/SAP/xp-clifford/erratt"
+)
+
+// ExitCodePartialFailure is the exit code of the export subcommand
+// when the export finishes but errors are reported with the Error
+// method of the EventHandler.
+const ExitCodePartialFailure = 2
+
+// severity is the level of a message reported by the export
+// function.
+type severity string
+
+const (
+	severityInfo    severity = "info"
+	severityWarning severity = "warning"
+	severityError   severity = "error"
+)
+
+// message is an info, warning or error reported by the export
+// function.
+type message struct {
+	severity severity
+	err      error
+}
+
+func (m message) log(logger *slog.Logger) {
+	switch m.severity {
+	case severityInfo:
+		erratt.SlogInfoWith(m.err, logger)
+	case severityError:
+		erratt.SlogWith(m.err, logger)
+	default:
+		erratt.SlogWarnWith(m.err, logger)
+	}
+}
+
+// runResult counts the outcome of an export run.
+type runResult struct {
+	Resources int
+	Infos     int
+	Warnings  int
+	Errors    int
+}
+
+func (r *runResult) count(m message) {
+	switch m.severity {
+	case severityInfo:
+		r.Infos++
+	case severityError:
+		r.Errors++
+	default:
+		r.Warnings++
+	}
+}
+
+// attrs returns the counters as key-value pairs.
+func (r *runResult) attrs() []any {
+	return []any{
+		"resources", r.Resources,
+		"infos", r.Infos,
+		"warnings", r.Warnings,
+		"errors", r.Errors,
+	}
+}
+
+// err returns the error that the export subcommand shall return for
+// a finished run. It is nil if no errors are reported.
+func (r *runResult) err() error {
+	if r.Errors == 0 {
+		return nil
+	}
+	return erratt.New("Export finished with errors", r.attrs()...)
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/cli"
+	"github.com/SAP/xp-clifford/erratt"
+)
+
+var _ = Describe("The messages", func() {
+	It("are logged at the level of their severity", func() {
+		buf := &bytes.Buffer{}
+		logger := newTextLogger(buf)
+		message{severity: severityInfo, err: errors.New("skipped")}.log(logger)
+		message{severity: severityWarning, err: errors.New("slow API")}.log(logger)
+		message{severity: severityError, err: erratt.New("timeout", "kind", "Bucket")}.log(logger)
+		Expect(logLines(buf)).To(Equal([]string{
+			`level=INFO msg=skipped`,
+			`level=WARN msg="slow API"`,
+			`level=ERROR msg=timeout kind=Bucket`,
+		}))
+	})
+})
+
+var _ = Describe("The result of a run", func() {
+	It("counts the messages by severity", func() {
+		r := runResult{Resources: 4}
+		r.count(message{severity: severityInfo, err: errors.New("skipped")})
+		r.count(message{severity: severityWarning, err: errors.New("slow API")})
+		r.count(message{severity: severityWarning, err: errors.New("slow API")})
+		Expect(r.attrs()).To(Equal([]any{"resources", 4, "infos", 1, "warnings", 2, "errors", 0}))
+		Expect(r.err()).NotTo(HaveOccurred())
+		r.count(message{severity: severityError, err: errors.New("timeout")})
+		Expect(r.err()).To(MatchError("Export finished with errors"))
+	})
+
+	It("is logged when the export finishes", func() {
+		buf := &bytes.Buffer{}
+		p := newTestPipeline(&recordingOutput{})
+		p.logger = newTextLogger(buf)
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Info(errors.New("skipped"))
+			events.Resource(newResource("Bucket", "logs"))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logLines(buf)).To(ContainElement(
+			`level=INFO msg="Export finished" resources=1 infos=1 warnings=0 errors=0`))
+	})
+
+	It("sets the exit code of a partial failure", func() {
+		p := newTestPipeline(&recordingOutput{})
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Error(errors.New("cannot export bucket"))
+			events.Resource(newResource("Bucket", "logs"))
+			return nil
+		})
+		Expect(err).To(HaveOccurred())
+		Expect(cli.ExitCode(err)).To(Equal(ExitCodePartialFailure))
+		Expect(p.result.Resources).To(Equal(1))
+	})
+
+	It("sets the default exit code of a failure", func() {
+		p := newTestPipeline(&recordingOutput{})
+		err := runExport(context.Background(), p, func(_ context.Context, _ EventHandler) error {
+			return errors.New("login failed")
+		})
+		Expect(err).To(MatchError("login failed"))
+		Expect(cli.ExitCode(err)).To(Equal(1))
+	})
+})
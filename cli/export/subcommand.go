@@ -2,12 +2,19 @@ package export
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/SAP/xp-clifford/cli"
 	"github.com/SAP/xp-clifford/cli/configparam"
 	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 func init() {
@@ -18,6 +25,12 @@ type exportSubCommand struct {
 	runCommand              func(context.Context, EventHandler) error
 	configParams            configparam.ParamList
 	exportableResourceKinds []string
+	scheme                  *runtime.Scheme
+	removedFields           map[string][]string
+	encryptedFields         []string
+	// addedParams are the config parameters added with
+	// AddConfigParams.
+	addedParams configparam.ParamList
 }
 
 var ResourceKindParam = configparam.StringSlice("exported kinds", "Resource kinds to export").
@@ -30,6 +43,129 @@ var OutputParam = configparam.String("output", "redirect the YAML output to a fi
 	WithFlagName("output").
 	WithEnvVarName("OUTPUT")
 
+var SplitByParam = configparam.String("split-by", "split the output into one file per resource kind (kind)").
+	WithFlagName("split-by").
+	WithEnvVarName("SPLIT_BY")
+
+var SplitResourcesParam = configparam.Int("split-resources", "start a new output file after the given number of resources").
+	WithFlagName("split-resources").
+	WithEnvVarName("SPLIT_RESOURCES")
+
+var SplitSizeParam = configparam.Int("split-size", "start a new output file after the given size in megabytes").
+	WithFlagName("split-size").
+	WithEnvVarName("SPLIT_SIZE")
+
+var OutputTemplateParam = configparam.String("output-template", "render each resource with the given Go template instead of YAML").
+	WithFlagName("output-template").
+	WithEnvVarName("OUTPUT_TEMPLATE")
+
+var JSONPathParam = configparam.String("jsonpath", "render each resource with the given JSONPath expression instead of YAML").
+	WithFlagName("jsonpath").
+	WithEnvVarName("JSONPATH")
+
+var CanonicalOrderParam = configparam.Bool("canonical-order", "emit apiVersion, kind, metadata and spec first in the YAML output").
+	WithFlagName("canonical-order").
+	WithEnvVarName("CANONICAL_ORDER")
+
+var LiteralBlocksParam = configparam.Bool("literal-blocks", "render multi-line strings as literal block scalars in the YAML output").
+	WithFlagName("literal-blocks").
+	WithEnvVarName("LITERAL_BLOCKS")
+
+var FoldWidthParam = configparam.Int("fold-width", "fold strings longer than the given width in the YAML output").
+	WithFlagName("fold-width").
+	WithEnvVarName("FOLD_WIDTH")
+
+var ColorParam = configparam.String("color", "syntax-highlight the YAML printed on the console (auto, always, never)").
+	WithFlagName("color").
+	WithEnvVarName("COLOR").
+	WithDefaultValue(colorAuto)
+
+var ColorStyleParam = configparam.String("color-style", "glamour style of the syntax highlighting (a standard style name or a JSON style file)").
+	WithFlagName("color-style").
+	WithEnvVarName("COLOR_STYLE").
+	WithDefaultValue(yaml.DefaultStyle)
+
+var FilterParam = configparam.String("filter", "export only the resources for which the given CEL expression evaluates to true").
+	WithFlagName("filter").
+	WithEnvVarName("FILTER")
+
+var EventsFileParam = configparam.String("events-file", "write the export events as newline-delimited JSON to a file").
+	WithFlagName("events-file").
+	WithEnvVarName("EVENTS_FILE")
+
+var EventsFormatParam = configparam.String("events-format", "print the export events in the given format (json) to STDERR").
+	WithFlagName("events-format").
+	WithEnvVarName("EVENTS_FORMAT")
+
+var ProgressParam = configparam.Bool("progress", "show the progress of the export").
+	WithFlagName("progress").
+	WithEnvVarName("PROGRESS").
+	WithDefaultValue(true)
+
+var AggregateWarningsParam = configparam.Bool("aggregate-warnings", "print only the first occurrences of repeated warnings").
+	WithFlagName("aggregate-warnings").
+	WithEnvVarName("AGGREGATE_WARNINGS").
+	WithDefaultValue(true)
+
+var WarningLimitParam = configparam.Int("warning-limit", "number of occurrences of a repeated warning that are printed").
+	WithFlagName("warning-limit").
+	WithEnvVarName("WARNING_LIMIT").
+	WithDefaultValue(3)
+
+var WarningGroupByParam = configparam.StringSlice("warning-group-by", "warning attributes that distinguish repeated warnings").
+	WithFlagName("warning-group-by").
+	WithEnvVarName("WARNING_GROUP_BY")
+
+var OnErrorParam = configparam.String("on-error", "keep or discard the output written before the export fails (keep, discard)").
+	WithFlagName("on-error").
+	WithEnvVarName("ON_ERROR").
+	WithDefaultValue(onErrorDiscard)
+
+var ValidateCRDsParam = configparam.String("validate-crds", "validate the exported resources against the CRDs found in the given directory").
+	WithFlagName("validate-crds").
+	WithEnvVarName("VALIDATE_CRDS")
+
+var NormalizeParam = configparam.Bool("normalize", "remove null and empty values, status and managed fields from the exported resources").
+	WithFlagName("normalize").
+	WithEnvVarName("NORMALIZE")
+
+var RemoveFieldsParam = configparam.StringSlice("remove-fields", "remove the fields with the given paths ([KIND:]PATH) from the exported resources").
+	WithFlagName("remove-fields").
+	WithEnvVarName("REMOVE_FIELDS")
+
+var EncryptFieldsParam = configparam.StringSlice("encrypt-fields", "encrypt the values of the fields with the given names in the exported resources").
+	WithFlagName("encrypt-fields").
+	WithEnvVarName("ENCRYPT_FIELDS")
+
+var AgeRecipientsParam = configparam.StringSlice("age-recipients", "age public keys that can decrypt the encrypted fields").
+	WithFlagName("age-recipients").
+	WithEnvVarName("AGE_RECIPIENTS")
+
+var GitRepoParam = configparam.String("git-repo", "write the output files into the given Git working tree and commit them").
+	WithFlagName("git-repo").
+	WithEnvVarName("GIT_REPO")
+
+var GitBranchPrefixParam = configparam.String("git-branch-prefix", "commit the output of each run on a new branch with the given name prefix").
+	WithFlagName("git-branch-prefix").
+	WithEnvVarName("GIT_BRANCH_PREFIX")
+
+var WatchParam = configparam.Bool("watch", "re-export periodically and write the output only if the exported resources change").
+	WithFlagName("watch").
+	WithEnvVarName("WATCH")
+
+var IntervalParam = configparam.Duration("interval", "time between the export runs in watch mode").
+	WithFlagName("interval").
+	WithEnvVarName("INTERVAL").
+	WithDefaultValue(10 * time.Minute)
+
+var ForceParam = configparam.Bool("force", "overwrite existing output files").
+	WithFlagName("force").
+	WithEnvVarName("FORCE")
+
+var AppendParam = configparam.Bool("append", "append to existing output files").
+	WithFlagName("append").
+	WithEnvVarName("APPEND")
+
 var (
 	_         cli.SubCommand = &exportSubCommand{}
 	exportCmd                = &exportSubCommand{
@@ -39,6 +175,35 @@ var (
 		configParams: configparam.ParamList{
 			ResourceKindParam,
 			OutputParam,
+			SplitByParam,
+			SplitResourcesParam,
+			SplitSizeParam,
+			ForceParam,
+			AppendParam,
+			OnErrorParam,
+			GitRepoParam,
+			GitBranchPrefixParam,
+			WatchParam,
+			IntervalParam,
+			OutputTemplateParam,
+			JSONPathParam,
+			CanonicalOrderParam,
+			LiteralBlocksParam,
+			FoldWidthParam,
+			ColorParam,
+			ColorStyleParam,
+			FilterParam,
+			ValidateCRDsParam,
+			NormalizeParam,
+			RemoveFieldsParam,
+			EncryptFieldsParam,
+			AgeRecipientsParam,
+			EventsFileParam,
+			EventsFormatParam,
+			ProgressParam,
+			AggregateWarningsParam,
+			WarningLimitParam,
+			WarningGroupByParam,
 		},
 	}
 )
@@ -65,20 +230,80 @@ func (c *exportSubCommand) MustIgnoreConfigFile() bool {
 
 func (c *exportSubCommand) GetRun() func(context.Context) error {
 	return func(ctx context.Context) error {
-		evHandler := newEventHandler(ctx)
-		wg := sync.WaitGroup{}
-		wg.Add(1)
-		go printErrors(ctx, &wg, evHandler.errorHandler.ch)
-
-		wg.Add(1)
-		go handleResources(ctx, &wg, evHandler.resourceHandler.ch, evHandler.errorHandler.ch)
-		err := c.runCommand(ctx, evHandler)
+		// the log lines are kept off the resources printed on
+		// STDOUT
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(newLogger(os.Stderr))
+		events, err := openEventLog()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := events.Close(); err != nil {
+				erratt.Slog(erratt.Errorf("Cannot close events file: %w", err))
+			}
+		}()
+		if WatchParam.Value() {
+			return c.watch(ctx, events)
+		}
+		p, err := newPipeline(c, events)
 		if err != nil {
 			return err
 		}
-		wg.Wait()
-		return nil
+		return c.run(ctx, p, nil)
+	}
+}
+
+// run invokes the export function once and passes the reported
+// resources through p. In watch mode, prev is the snapshot of the
+// previously written output, see [pipeline.commit].
+func (c *exportSubCommand) run(ctx context.Context, p *pipeline, prev snapshot) error {
+	p.events.runStarted()
+	if bars, ok := p.progress.(*barDisplay); ok {
+		// the log lines of the run are printed above the progress
+		// bars
+		defer slog.SetDefault(slog.Default())
+		slog.SetDefault(newLogger(bars))
+	}
+	evHandler := newEventHandler(ctx, p)
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go printMessages(ctx, &wg, p, evHandler.messageHandler.ch)
+
+	wg.Add(1)
+	go handleResources(ctx, &wg, p, evHandler.resourceHandler.ch)
+
+	wg.Add(1)
+	go showProgress(ctx, &wg, p.progress, p.events, evHandler.progressHandler.ch)
+	runErr := c.runCommand(ctx, evHandler)
+	evHandler.close()
+	wg.Wait()
+	// The stop event is recorded after the events reported before
+	// Stop are processed.
+	if evHandler.stopped.Load() {
+		p.events.stop()
+	}
+	p.reportUnusedFields()
+	p.aggregator.summary(p.logger)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		// The export function may return the error of the context
+		// or stop without an error when it is cancelled.
+		p.events.cancelled()
+		if runErr == nil || errors.Is(runErr, ctxErr) {
+			runErr = erratt.Errorf("export is interrupted: %w", ctxErr)
+		}
+	}
+	if runErr != nil {
+		p.handleFailure()
+	} else if runErr = p.commit(prev); runErr == nil {
+		if err := p.result.err(); err != nil {
+			runErr = cli.WithExitCode(err, ExitCodePartialFailure)
+		} else {
+			p.logger.Info("Export finished", p.result.attrs()...)
+		}
 	}
+	p.events.runFinished(&p.result, runErr)
+	return runErr
 }
 
 func SetCommand(cmd func(context.Context, EventHandler) error) {
@@ -87,6 +312,7 @@ func SetCommand(cmd func(context.Context, EventHandler) error) {
 
 func AddConfigParams(param ...configparam.ConfigParam) {
 	exportCmd.configParams = append(exportCmd.configParams, param...)
+	exportCmd.addedParams = append(exportCmd.addedParams, param...)
 }
 
 func GetConfigParams() configparam.ParamList {
@@ -0,0 +1,177 @@
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SAP/xp-clifford/erratt"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apiextensions-apiserver/pkg/apis/apiextensions"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	structuralschema "k8s.io/apiextensions-apiserver/pkg/apiserver/schema"
+	"k8s.io/apiextensions-apiserver/pkg/apiserver/schema/pruning"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// versionSchema validates the resources of a version of a CRD. The
+// schema is validated with the OpenAPI validator of kube-openapi
+// rather than the validation package of the API server, which would
+// pull the dependencies of the API server into the CLI tool.
+type versionSchema struct {
+	validator  *validate.SchemaValidator
+	structural *structuralschema.Structural
+}
+
+// crdValidator validates resources against the OpenAPI schemas of
+// CRDs.
+type crdValidator struct {
+	schemas map[schema.GroupVersionKind]*versionSchema
+}
+
+func newCRDValidator() *crdValidator {
+	return &crdValidator{
+		schemas: map[schema.GroupVersionKind]*versionSchema{},
+	}
+}
+
+// addCRD registers the schemas of all versions of crd.
+func (v *crdValidator) addCRD(crd *apiextensionsv1.CustomResourceDefinition) erratt.Error {
+	for _, version := range crd.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		gvk := schema.GroupVersionKind{
+			Group:   crd.Spec.Group,
+			Version: version.Name,
+			Kind:    crd.Spec.Names.Kind,
+		}
+		internal := &apiextensions.JSONSchemaProps{}
+		if err := apiextensionsv1.Convert_v1_JSONSchemaProps_To_apiextensions_JSONSchemaProps(version.Schema.OpenAPIV3Schema, internal, nil); err != nil {
+			return erratt.Errorf("Cannot convert CRD schema: %w", err).With("crd", crd.Name, "gvk", gvk.String())
+		}
+		structural, err := structuralschema.NewStructural(internal)
+		if err != nil {
+			return erratt.Errorf("CRD schema is not structural: %w", err).With("crd", crd.Name, "gvk", gvk.String())
+		}
+		v.schemas[gvk] = &versionSchema{
+			validator:  validate.NewSchemaValidator(structural.ToKubeOpenAPI(), nil, "", strfmt.Default),
+			structural: structural,
+		}
+	}
+	return nil
+}
+
+// loadFile registers the CRDs found in the YAML or JSON file name.
+// Other documents of the file are ignored.
+func (v *crdValidator) loadFile(name string) erratt.Error {
+	f, err := os.Open(filepath.Clean(name))
+	if err != nil {
+		return erratt.Errorf("Cannot open CRD file: %w", err).With("file", name)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		doc := map[string]any{}
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return erratt.Errorf("Cannot decode CRD file: %w", err).With("file", name)
+		}
+		if doc["kind"] != "CustomResourceDefinition" {
+			continue
+		}
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(doc, crd); err != nil {
+			return erratt.Errorf("Cannot decode CRD: %w", err).With("file", name)
+		}
+		if err := v.addCRD(crd); err != nil {
+			return err.With("file", name)
+		}
+	}
+}
+
+// loadCRDs registers the CRDs found in the YAML and JSON files of
+// dir and its subdirectories.
+func loadCRDs(dir string) (*crdValidator, erratt.Error) {
+	v := newCRDValidator()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			if !d.IsDir() {
+				if err := v.loadFile(path); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, erratt.Errorf("Cannot load CRDs: %w", err).With("validate-crds", dir)
+	}
+	slog.Debug("CRDs loaded", "validate-crds", dir, "versions", len(v.schemas))
+	return v, nil
+}
+
+// validate returns the violations of the CRD schema by res. A
+// resource without a matching CRD version has no violations.
+func (v *crdValidator) validate(res resource.Object) ([]error, erratt.Error) {
+	gvk := res.GetObjectKind().GroupVersionKind()
+	s, ok := v.schemas[gvk]
+	if !ok {
+		slog.Debug("No CRD found for resource", "gvk", gvk.String(), "name", res.GetName())
+		return nil, nil
+	}
+	content, err := objectContent(res)
+	if err != nil {
+		return nil, erratt.Errorf("cannot convert resource: %w", err)
+	}
+	// The content is copied by a JSON round trip, because pruning
+	// modifies it and the validation expects JSON types.
+	b, err := json.Marshal(content)
+	if err != nil {
+		return nil, erratt.Errorf("cannot convert resource: %w", err)
+	}
+	content = map[string]any{}
+	if err := utiljson.Unmarshal(b, &content); err != nil {
+		return nil, erratt.Errorf("cannot convert resource: %w", err)
+	}
+	violations := []error{}
+	for _, validationErr := range s.validator.Validate(content).Errors {
+		violations = append(violations, erratt.New("Resource violates the CRD schema", "violation", validationErr.Error()))
+	}
+	unknown := pruning.PruneWithOptions(content, s.structural, true, structuralschema.UnknownFieldPathOptions{
+		TrackUnknownFieldPaths: true,
+	})
+	for _, path := range unknown {
+		violations = append(violations, erratt.New("Resource has a field unknown to the CRD schema", "field", path))
+	}
+	return violations, nil
+}
+
+// newCRDValidatorFromParams returns the crdValidator configured by
+// the ValidateCRDsParam, or nil if validation is not configured.
+func newCRDValidatorFromParams() (*crdValidator, erratt.Error) {
+	dir := ValidateCRDsParam.Value()
+	if dir == "" {
+		return nil, nil
+	}
+	return loadCRDs(dir)
+}
@@ -0,0 +1,133 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/cli/export/internal/warning"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const bucketCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: buckets.test.example.com
+spec:
+  group: test.example.com
+  names:
+    kind: Bucket
+    plural: buckets
+  scope: Cluster
+  versions:
+  - name: v1
+    served: true
+    storage: true
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          apiVersion:
+            type: string
+          kind:
+            type: string
+          metadata:
+            type: object
+          spec:
+            type: object
+            required:
+            - region
+            properties:
+              region:
+                type: string
+              size:
+                type: integer
+                minimum: 1
+`
+
+// writeCRDs writes the bucket CRD with an unrelated document into a
+// temporary directory and returns the directory.
+func writeCRDs() string {
+	dir := GinkgoT().TempDir()
+	content := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: unrelated\n---\n" + bucketCRD
+	ExpectWithOffset(1, os.WriteFile(filepath.Join(dir, "crds.yaml"), []byte(content), 0o600)).To(Succeed())
+	ExpectWithOffset(1, os.WriteFile(filepath.Join(dir, "README.md"), []byte("# CRDs\n"), 0o600)).To(Succeed())
+	return dir
+}
+
+// newBucket returns a Bucket with the given spec.
+func newBucket(spec map[string]any) *unstructured.Unstructured {
+	res := newResource("Bucket", "logs")
+	res.Object["spec"] = spec
+	return res
+}
+
+var _ = Describe("The CRD validator", func() {
+	var v *crdValidator
+	BeforeEach(func() {
+		var err error
+		v, err = loadCRDs(writeCRDs())
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("loads the CRDs of the YAML files", func() {
+		Expect(v.schemas).To(HaveLen(1))
+	})
+
+	It("accepts a valid resource", func() {
+		Expect(v.validate(newBucket(map[string]any{"region": "eu10", "size": 3}))).To(BeEmpty())
+	})
+
+	It("reports the violations of the schema", func() {
+		violations, err := v.validate(newBucket(map[string]any{"size": 0}))
+		Expect(err).NotTo(HaveOccurred())
+		texts := []string{}
+		for _, violation := range violations {
+			texts = append(texts, warning.Text(violation))
+		}
+		Expect(texts).To(ConsistOf(
+			"WARNING: Resource violates the CRD schema violation=spec.region in body is required",
+			"WARNING: Resource violates the CRD schema violation=spec.size in body should be greater than or equal to 1",
+		))
+	})
+
+	It("reports the fields unknown to the schema", func() {
+		violations, err := v.validate(newBucket(map[string]any{"region": "eu10", "tier": "hot"}))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(violations).To(HaveLen(1))
+		Expect(warning.Text(violations[0])).To(Equal("WARNING: Resource has a field unknown to the CRD schema field=spec.tier"))
+	})
+
+	It("does not validate resources without a CRD", func() {
+		Expect(v.validate(newResource("User", "admin"))).To(BeEmpty())
+	})
+
+	It("rejects a directory with an invalid CRD file", func() {
+		dir := GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "crds.yaml"), []byte("kind: [\n"), 0o600)).To(Succeed())
+		_, err := loadCRDs(dir)
+		Expect(err).To(MatchError(ContainSubstring("Cannot load CRDs")))
+	})
+
+	It("adds the violations to the written resources", func() {
+		out := &recordingOutput{}
+		p := newTestPipeline(out)
+		p.validator = v
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			events.Resource(newBucket(map[string]any{"size": "large"}))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.resources).To(HaveLen(1))
+		comment, commentOut := out.resources[0].(*yaml.ResourceWithComment).Comment()
+		Expect(comment).To(ContainSubstring("WARNING: Resource violates the CRD schema violation=spec.region in body is required"))
+		Expect(comment).To(ContainSubstring("violation=spec.size in body must be of type integer"))
+		Expect(commentOut).To(BeFalse())
+		Expect(p.result.Warnings).To(Equal(2))
+	})
+})
@@ -0,0 +1,39 @@
+package export
+
+import (
+	"github.com/SAP/xp-clifford/cli/export/internal/warning"
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// Blocking marks err as a blocking warning. When a blocking warning
+// is reported for a resource with WarnResource, the resource is
+// commented out in the output.
+func Blocking(err error) error {
+	return warning.Blocking(err)
+}
+
+// IsBlocking reports whether err is marked as a blocking warning.
+func IsBlocking(err error) bool {
+	return warning.IsBlocking(err)
+}
+
+// unwrapResource returns the resource wrapped by a
+// [yaml.ResourceWithComment], or res itself.
+func unwrapResource(res resource.Object) resource.Object {
+	if r, ok := res.(*yaml.ResourceWithComment); ok {
+		return r.Resource()
+	}
+	return res
+}
+
+// resourceWarning returns the warning err reported for res,
+// extended with the kind and the name of res.
+func resourceWarning(res resource.Object, err error) erratt.Error {
+	return erratt.Errorf("%w", err).With(
+		"kind", res.GetObjectKind().GroupVersionKind().Kind,
+		"name", res.GetName(),
+	)
+}
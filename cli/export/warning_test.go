@@ -0,0 +1,32 @@
+package export
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+)
+
+var _ = Describe("The resource warnings", func() {
+	It("are added to the written resources", func() {
+		out := &recordingOutput{}
+		p := newTestPipeline(out)
+		err := runExport(context.Background(), p, func(_ context.Context, events EventHandler) error {
+			logs := newResource("Bucket", "logs")
+			events.WarnResource(logs, Blocking(errors.New("missing reference")))
+			events.Resource(logs)
+			events.Resource(newResource("Bucket", "backups"))
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out.resources).To(HaveLen(2))
+		comment, commentOut := out.resources[0].(*yaml.ResourceWithComment).Comment()
+		Expect(comment).To(Equal("BLOCKING WARNING: missing reference\n"))
+		Expect(commentOut).To(BeTrue())
+		Expect(out.resources[1]).NotTo(BeAssignableToTypeOf(&yaml.ResourceWithComment{}))
+		Expect(p.result.Warnings).To(Equal(1))
+	})
+})
@@ -0,0 +1,181 @@
+package export
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SAP/xp-clifford/erratt"
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// snapshot maps the exported resources of a run, identified by kind,
+// namespace and name, to the digests of their YAML representation.
+type snapshot map[string][sha256.Size]byte
+
+// snapshotKey returns the key of res in a snapshot.
+func snapshotKey(res resource.Object) string {
+	parts := []string{res.GetObjectKind().GroupVersionKind().Kind}
+	if ns := res.GetNamespace(); ns != "" {
+		parts = append(parts, ns)
+	}
+	return strings.Join(append(parts, res.GetName()), "/")
+}
+
+// add records res in s. Resources with the same key are told apart by
+// their order.
+func (s snapshot) add(res resource.Object) {
+	key := snapshotKey(res)
+	for i := 2; ; i++ {
+		if _, ok := s[key]; !ok {
+			break
+		}
+		key = fmt.Sprintf("%s#%d", snapshotKey(res), i)
+	}
+	// The YAML representation covers the comments of the resource.
+	// Resources that cannot be marshalled are not written, so an
+	// error only makes the digest differ.
+	y, err := yaml.Marshal(res)
+	if err != nil {
+		y = err.Error()
+	}
+	s[key] = sha256.Sum256([]byte(y))
+}
+
+// snapshotDiff holds the keys of the resources that have been added,
+// changed or removed between two snapshots.
+type snapshotDiff struct {
+	added     []string
+	changed   []string
+	removed   []string
+	unchanged int
+}
+
+// diffSnapshots compares the snapshot of a run with the snapshot of
+// the previous run.
+func diffSnapshots(prev, cur snapshot) snapshotDiff {
+	d := snapshotDiff{}
+	for _, key := range slices.Sorted(maps.Keys(cur)) {
+		digest, ok := prev[key]
+		switch {
+		case !ok:
+			d.added = append(d.added, key)
+		case digest != cur[key]:
+			d.changed = append(d.changed, key)
+		default:
+			d.unchanged++
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(prev)) {
+		if _, ok := cur[key]; !ok {
+			d.removed = append(d.removed, key)
+		}
+	}
+	return d
+}
+
+// isEmpty reports whether no resource has been added, changed or
+// removed.
+func (d snapshotDiff) isEmpty() bool {
+	return len(d.added) == 0 && len(d.changed) == 0 && len(d.removed) == 0
+}
+
+// attrs returns the counters of d as key-value pairs.
+func (d snapshotDiff) attrs() []any {
+	return []any{
+		"added", len(d.added),
+		"changed", len(d.changed),
+		"removed", len(d.removed),
+		"unchanged", d.unchanged,
+	}
+}
+
+// log prints the summary of d, and the changed resources at debug
+// level.
+func (d snapshotDiff) log() {
+	slog.Info("Exported resources compared to the previous run", d.attrs()...)
+	for _, change := range []struct {
+		msg  string
+		keys []string
+	}{
+		{"Resource added", d.added},
+		{"Resource changed", d.changed},
+		{"Resource removed", d.removed},
+	} {
+		for _, key := range change.keys {
+			slog.Debug(change.msg, "resource", key)
+		}
+	}
+}
+
+// commit commits the output of a successful run. In watch mode, prev
+// is the snapshot of the previously written output, and the output
+// is discarded if no resource has changed since.
+func (p *pipeline) commit(prev snapshot) error {
+	if p.snapshot != nil {
+		d := diffSnapshots(prev, p.snapshot)
+		d.log()
+		p.events.changes(d)
+		if prev != nil && d.isEmpty() {
+			slog.Info("Exported resources are unchanged, the output is not written")
+			return p.out.Abort()
+		}
+	}
+	if err := p.out.Commit(); err != nil {
+		return err
+	}
+	p.committed = true
+	return nil
+}
+
+// watch runs the export repeatedly until ctx is cancelled, waiting
+// for the configured interval after each run. The output is written
+// only if the exported resources have changed since the last written
+// output. Failed runs are logged and retried after the interval.
+func (c *exportSubCommand) watch(ctx context.Context, events *eventLog) error {
+	interval := IntervalParam.Value()
+	switch {
+	case interval <= 0:
+		return erratt.New("Watch mode requires a positive interval, use --interval", "interval", interval)
+	case OutputParam.Value() == "":
+		// the output of each changed run would be printed on the
+		// console again
+		return erratt.New("Watch mode requires an output file, use --output")
+	case AppendParam.Value():
+		return erratt.New("The watch and append options cannot be used together")
+	}
+	var prev snapshot
+	// written are the output files of the last written run, which the
+	// next runs replace without --force
+	var written []string
+	for {
+		p, err := newPipeline(c, events, written...)
+		if err != nil {
+			return err
+		}
+		p.snapshot = snapshot{}
+		if err := c.run(ctx, p, prev); err != nil && ctx.Err() == nil {
+			erratt.Slog(erratt.Errorf("Export run failed: %w", err))
+		}
+		if p.committed {
+			prev = p.snapshot
+			written = committedFiles(p.out)
+		}
+		if ctx.Err() == nil {
+			slog.Info("Waiting for the next export run", "interval", interval)
+		}
+		select {
+		case <-ctx.Done():
+			slog.Info("Watch mode is stopped")
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
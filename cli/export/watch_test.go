@@ -0,0 +1,147 @@
+package export
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+)
+
+// newSnapshot returns the snapshot of the given resources.
+func newSnapshot(resources ...resource.Object) snapshot {
+	s := snapshot{}
+	for _, res := range resources {
+		s.add(res)
+	}
+	return s
+}
+
+var _ = Describe("diffSnapshots", func() {
+	It("lists the added, changed and removed resources", func() {
+		changed := newResource("Bucket", "logs")
+		changed.SetLabels(map[string]string{"team": "core"})
+		prev := newSnapshot(newResource("Bucket", "logs"), newResource("Bucket", "data"), newResource("User", "admin"))
+		cur := newSnapshot(changed, newResource("Bucket", "data"), newResource("User", "guest"))
+		d := diffSnapshots(prev, cur)
+		Expect(d.added).To(Equal([]string{"User/guest"}))
+		Expect(d.changed).To(Equal([]string{"Bucket/logs"}))
+		Expect(d.removed).To(Equal([]string{"User/admin"}))
+		Expect(d.unchanged).To(Equal(1))
+		Expect(d.isEmpty()).To(BeFalse())
+	})
+
+	It("tells resources with the same key apart by their order", func() {
+		s := newSnapshot(newResource("Bucket", "logs"), newResource("Bucket", "logs"))
+		Expect(s).To(HaveKey("Bucket/logs"))
+		Expect(s).To(HaveKey("Bucket/logs#2"))
+	})
+
+	It("finds no difference between equal snapshots", func() {
+		d := diffSnapshots(newSnapshot(newResource("Bucket", "logs")), newSnapshot(newResource("Bucket", "logs")))
+		Expect(d.isEmpty()).To(BeTrue())
+		Expect(d.unchanged).To(Equal(1))
+	})
+})
+
+var _ = Describe("A watch run", func() {
+	var path string
+	// written are the output files of the last written run.
+	var written []string
+	// runWatched runs the export of the given resources once, with prev
+	// as the snapshot of the previous run, and returns its pipeline.
+	runWatched := func(prev snapshot, resources ...resource.Object) *pipeline {
+		out, err := openOutput(nil, written...)
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+		p := newTestPipeline(out)
+		p.snapshot = snapshot{}
+		c := &exportSubCommand{runCommand: func(_ context.Context, events EventHandler) error {
+			for _, res := range resources {
+				events.Resource(res)
+			}
+			return nil
+		}}
+		ExpectWithOffset(1, c.run(context.Background(), p, prev)).To(Succeed())
+		if p.committed {
+			written = committedFiles(p.out)
+		}
+		return p
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "out.yaml")
+		written = nil
+		setParam(OutputParam, path)
+	})
+
+	It("writes the output of the first run", func() {
+		p := runWatched(nil, newResource("Bucket", "logs"))
+		Expect(p.committed).To(BeTrue())
+		Expect(documents(path)).To(Equal(1))
+	})
+
+	It("refuses to replace an existing output file on the first run", func() {
+		Expect(os.WriteFile(path, []byte("previous\n"), 0o600)).To(Succeed())
+		_, err := openOutput(nil, written...)
+		Expect(err).To(MatchError(ContainSubstring("Output file already exists")))
+		Expect(os.ReadFile(path)).To(Equal([]byte("previous\n")))
+	})
+
+	It("replaces only the output files of the previous runs", func() {
+		setParam(SplitByParam, splitByKind)
+		prev := runWatched(nil, newResource("Bucket", "logs")).snapshot
+		Expect(written).To(Equal([]string{filepath.Join(filepath.Dir(path), "out-bucket.yaml")}))
+		p := runWatched(prev, newResource("Bucket", "logs"), newResource("Bucket", "data"))
+		Expect(p.committed).To(BeTrue())
+		Expect(os.WriteFile(filepath.Join(filepath.Dir(path), "out-user.yaml"), []byte("previous\n"), 0o600)).To(Succeed())
+		_, err := openOutput(nil, written...)
+		Expect(err).To(MatchError(ContainSubstring("Output file already exists")))
+		Expect(err.Attrs()).To(Equal([]any{"output", filepath.Join(filepath.Dir(path), "out-user.yaml")}))
+	})
+
+	It("does not write the output if no resource has changed", func() {
+		prev := runWatched(nil, newResource("Bucket", "logs")).snapshot
+		Expect(os.WriteFile(path, []byte("previous\n"), 0o600)).To(Succeed())
+		p := runWatched(prev, newResource("Bucket", "logs"))
+		Expect(p.committed).To(BeFalse())
+		Expect(os.ReadFile(path)).To(Equal([]byte("previous\n")))
+		Expect(outputFiles(filepath.Dir(path))).To(Equal([]string{"out.yaml"}))
+	})
+
+	It("writes the output if a resource has changed", func() {
+		prev := runWatched(nil, newResource("Bucket", "logs")).snapshot
+		p := runWatched(prev, newResource("Bucket", "logs"), newResource("User", "admin"))
+		Expect(p.committed).To(BeTrue())
+		Expect(documents(path)).To(Equal(2))
+	})
+})
+
+var _ = Describe("The watch mode", func() {
+	watch := func() error {
+		return exportCmd.watch(context.Background(), newEventLog(nil))
+	}
+
+	BeforeEach(func() {
+		setParam(OutputParam, "out.yaml")
+		setParam(IntervalParam, time.Minute)
+	})
+
+	It("requires a positive interval", func() {
+		setParam(IntervalParam, time.Duration(0))
+		Expect(watch()).To(MatchError(ContainSubstring("Watch mode requires a positive interval")))
+	})
+
+	It("requires an output file", func() {
+		setParam(OutputParam, "")
+		Expect(watch()).To(MatchError("Watch mode requires an output file, use --output"))
+	})
+
+	It("rejects the append option", func() {
+		setParam(AppendParam, true)
+		Expect(watch()).To(MatchError("The watch and append options cannot be used together"))
+	})
+})
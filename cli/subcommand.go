@@ -18,7 +18,7 @@ func makeCobraRun(fn func(context.Context) error) func(*cobra.Command, []string)
 		defer cancel()
 		if err := fn(rootCtx); err != nil {
 			erratt.Slog(err)
-			os.Exit(1)
+			os.Exit(ExitCode(err))
 		}
 	}
 }
@@ -108,6 +108,18 @@ func Errorf(format string, a ...any) Error {
 	}
 }
 
+// SlogInfoWith logs err at [slog.LevelInfo] using logger.  If err
+// implements the Error interface, its attributes are emitted as
+// structured fields.
+func SlogInfoWith(err error, logger *slog.Logger) {
+	ewa := &errorWithAttrs{}
+	if errors.As(err, &ewa) {
+		logger.Info(ewa.text, ewa.Attrs()...)
+	} else {
+		logger.Info(err.Error())
+	}
+}
+
 // SlogWarnWith logs err at [slog.LevelWarn] using logger.  If err
 // implements the Error interface, its attributes are emitted as
 // structured fields.
@@ -133,6 +145,13 @@ func SlogWith(err error, logger *slog.Logger) {
 	}
 }
 
+// SlogInfo logs err at [slog.LevelInfo] using the [slog.Default]
+// logger.  If err implements the Error interface, its attributes are
+// emitted as structured fields.
+func SlogInfo(err error) {
+	SlogInfoWith(err, slog.Default())
+}
+
 // SlogWarn logs err at [slog.LevelWarn] using the [slog.Default]
 // logger.  If err implements the Error interface, its attributes are
 // emitted as structured fields.
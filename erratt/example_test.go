@@ -135,3 +135,20 @@ func ExampleSlog_multilevel_wrap() {
 	erratt.Slog(err)
 	//output: level=ERROR msg="outer error: medium error: deepest error" outer_reason=error deepest_reason=failure
 }
+
+func ExampleSlogInfo() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		AddSource: false,
+		Level:     nil,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// Remove time from the output for predictable test output.
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})))
+	err := erratt.New("resource skipped", "reason", "system-managed")
+	erratt.SlogInfo(err)
+	//output: level=INFO msg="resource skipped" reason=system-managed
+}
@@ -3,6 +3,8 @@ package yaml
 import (
 	"bytes"
 	"fmt"
+	"maps"
+	"slices"
 
 	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
 	"k8s.io/utils/ptr"
@@ -11,24 +13,47 @@ import (
 // CommentedYAML is an interface for resources that can have associated comments
 // in their YAML representation. When a resource implements this interface, the
 // output YAML will be commented out if the Comment method returns true as the
-// second result. The first (string) result will be placed above the YAML as a comment.
+// second result. The first (string) result will be placed above the YAML as a comment,
+// even if the YAML is not commented out.
 type CommentedYAML interface {
 	// Comment returns the comment text and a boolean indicating whether the resource
 	// should be commented out in the YAML output.
 	Comment() (string, bool)
 }
 
+// FieldCommentedYAML is an interface for resources that have comments attached
+// to individual fields in their YAML representation. The fields are identified by
+// dot-separated paths, like "spec.forProvider.quota". A numeric path segment selects
+// an element of a list. A dot that is part of a key is escaped with a backslash.
+type FieldCommentedYAML interface {
+	// FieldComments returns the comments placed after the fields, keyed by the
+	// field paths.
+	FieldComments() map[string]string
+	// CommentedOutFields returns the paths of the fields that are commented out.
+	CommentedOutFields() []string
+}
+
 // ResourceWithComment wraps a resource of any type with an optional comment.
 // This allows any type to implement the CommentedYAML interface.
 type ResourceWithComment struct {
 	// comment holds the optional comment text for the resource.
 	comment *string
+	// active keeps the resource uncommented when a comment is set.
+	active bool
+	// fieldComments holds the comments of individual fields.
+	fieldComments map[string]string
+	// commentedOutFields holds the paths of the commented out fields.
+	commentedOutFields []string
 	// Object is the embedded resource being wrapped.
 	resource.Object
 }
 
-// Ensure ResourceWithComment implements CommentedYAML interface.
-var _ CommentedYAML = &ResourceWithComment{}
+// Ensure ResourceWithComment implements the CommentedYAML and
+// FieldCommentedYAML interfaces.
+var (
+	_ CommentedYAML      = &ResourceWithComment{}
+	_ FieldCommentedYAML = &ResourceWithComment{}
+)
 
 // NewResourceWithComment creates a new ResourceWithComment wrapping the given resource
 // with no initial comment.
@@ -39,13 +64,20 @@ func NewResourceWithComment(res resource.Object) *ResourceWithComment {
 	}
 }
 
-// Comment returns the comment text and whether a comment is set.
+// Comment returns the comment text and whether the resource is commented out.
 // Returns an empty string and false if no comment is set.
 func (r *ResourceWithComment) Comment() (string, bool) {
 	if r.comment == nil {
 		return "", false
 	}
-	return *r.comment, true
+	return *r.comment, !r.active
+}
+
+// SetCommentOut sets whether the resource is commented out when a comment is set.
+// By default, resources with a comment are commented out. When commentOut is false,
+// the comment is placed above the uncommented YAML.
+func (r *ResourceWithComment) SetCommentOut(commentOut bool) {
+	r.active = !commentOut
 }
 
 // Resource returns the underlying wrapped resource object.
@@ -79,13 +111,54 @@ func (r *ResourceWithComment) AddComment(comment string) {
 }
 
 // CloneComment copies the comment from another CommentedYAML resource to this one.
-// If the source resource has a comment, it is copied. Otherwise, any existing comment
-// on this resource is cleared.
+// If the source resource has a comment, it is copied together with whether the
+// resource is commented out. Otherwise, any existing comment on this resource is cleared.
 func (r *ResourceWithComment) CloneComment(other CommentedYAML) {
 	c, ok := other.Comment()
-	if ok {
+	if ok || c != "" {
 		r.comment = &c
+		r.active = !ok
 	} else {
 		r.comment = nil
 	}
 }
+
+// AddFieldComment attaches a comment to the field identified by path. The comment
+// is placed on the line of the field. If the field already has a comment, the new
+// text is appended, separated by a semicolon. Comments of fields that do not exist
+// in the resource are ignored.
+func (r *ResourceWithComment) AddFieldComment(path, comment string) {
+	if r.fieldComments == nil {
+		r.fieldComments = map[string]string{}
+	}
+	if c, ok := r.fieldComments[path]; ok {
+		comment = c + "; " + comment
+	}
+	r.fieldComments[path] = comment
+}
+
+// CommentOutField comments out the field identified by path, together with its
+// value. The parents of the field that are left empty are commented out with
+// it. Fields that do not exist in the resource are ignored.
+func (r *ResourceWithComment) CommentOutField(path string) {
+	if !slices.Contains(r.commentedOutFields, path) {
+		r.commentedOutFields = append(r.commentedOutFields, path)
+	}
+}
+
+// FieldComments returns the comments of the fields, keyed by the field paths.
+func (r *ResourceWithComment) FieldComments() map[string]string {
+	return r.fieldComments
+}
+
+// CommentedOutFields returns the paths of the commented out fields.
+func (r *ResourceWithComment) CommentedOutFields() []string {
+	return r.commentedOutFields
+}
+
+// CloneFieldComments copies the field comments and the commented out fields from
+// another FieldCommentedYAML resource to this one, replacing the existing ones.
+func (r *ResourceWithComment) CloneFieldComments(other FieldCommentedYAML) {
+	r.fieldComments = maps.Clone(other.FieldComments())
+	r.commentedOutFields = slices.Clone(other.CommentedOutFields())
+}
@@ -0,0 +1,320 @@
+package yaml
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/SAP/xp-clifford/erratt"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	documentStartLine = "---"
+	documentEndLine   = "..."
+	commentPrefix     = "#"
+)
+
+// Decoder reads the resources of a multi-document YAML stream, like
+// the files written by [Marshal]. Documents that are commented out
+// are recognised and decoded too.
+type Decoder struct {
+	r *bufio.Reader
+	// line is the number of the last read line.
+	line int
+	// pending are the lines that have been read ahead but not
+	// processed yet.
+	pending []string
+}
+
+// NewDecoder creates a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r: bufio.NewReader(r),
+	}
+}
+
+// readLine returns the next line without the line terminator. It
+// returns io.EOF if there are no more lines.
+func (d *Decoder) readLine() (string, error) {
+	if len(d.pending) > 0 {
+		line := d.pending[0]
+		d.pending = d.pending[1:]
+		d.line++
+		return line, nil
+	}
+	line, err := d.r.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	d.line++
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+// unreadLines makes lines the next lines returned by readLine.
+func (d *Decoder) unreadLines(lines ...string) {
+	d.pending = append(slices.Clone(lines), d.pending...)
+	d.line -= len(lines)
+}
+
+// isCommentedDocumentStart reports whether line is the commented out
+// document start marker written by writeCommentedDocument.
+func isCommentedDocumentStart(line string) bool {
+	return strings.HasPrefix(line, commentPrefix) && uncomment(line) == documentStartLine
+}
+
+// startsCommentedDocument reports whether line, which has been read
+// already, starts a document commented out by writeCommentedDocument:
+// it is either the commented out document start marker, or it opens
+// a comment block enclosed in "#" lines that is followed by the
+// marker. The lines read ahead are returned by the next readLine.
+// Other comments are ordinary comments.
+func (d *Decoder) startsCommentedDocument(line string) (bool, error) {
+	if isCommentedDocumentStart(line) {
+		return true, nil
+	}
+	if line != commentPrefix {
+		return false, nil
+	}
+	ahead := []string{}
+	defer func() {
+		d.unreadLines(ahead...)
+	}()
+	for {
+		next, err := d.readLine()
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		ahead = append(ahead, next)
+		switch {
+		case next == commentPrefix:
+			// the end of the comment block
+			next, err = d.readLine()
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			ahead = append(ahead, next)
+			return isCommentedDocumentStart(next), nil
+		case !strings.HasPrefix(next, commentPrefix):
+			return false, nil
+		}
+	}
+}
+
+// uncomment removes the comment prefix of a line written by
+// writeCommentedDocument.
+func uncomment(line string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(line, commentPrefix), " ")
+}
+
+// Decode returns the next resource of the stream. It returns io.EOF
+// if there are no more resources.
+//
+// A resource that is commented out is returned as a
+// [ResourceWithComment] with its comment restored. A resource that
+// is preceded by a comment right after the document start marker is
+// returned as a [ResourceWithComment] that is not commented out.
+// Other resources are returned as [unstructured.Unstructured].
+// Field comments are not restored. Comments that precede a document
+// start marker, like a comment at the top of the stream, are ignored.
+func (d *Decoder) Decode() (resource.Object, error) {
+	for {
+		line, err := d.readLine()
+		if err != nil {
+			return nil, err
+		}
+		var res resource.Object
+		switch {
+		case strings.TrimSpace(line) == "", line == documentEndLine:
+			continue
+		case line == documentStartLine:
+			res, err = d.decodeDocument()
+		default:
+			var commentedOut bool
+			if commentedOut, err = d.startsCommentedDocument(line); err != nil {
+				return nil, err
+			}
+			d.unreadLines(line)
+			if commentedOut {
+				res, err = d.decodeCommentedDocument()
+			} else {
+				// a document without start marker, or ordinary
+				// comments before a document start marker
+				res, err = d.decodeDocument()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res != nil {
+			return res, nil
+		}
+	}
+}
+
+// decodeDocument decodes a document that is not commented out. The
+// comment lines that precede the content of the document are
+// restored as its comment. The document ends at the document end
+// marker, or at the start of the next document, whether it is
+// commented out or not.
+func (d *Decoder) decodeDocument() (resource.Object, error) {
+	start := d.line + 1
+	comment := []string{}
+	content := &strings.Builder{}
+	for {
+		line, err := d.readLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == documentEndLine {
+			break
+		}
+		if line == documentStartLine {
+			d.unreadLines(line)
+			break
+		}
+		commentedOut, err := d.startsCommentedDocument(line)
+		if err != nil {
+			return nil, err
+		}
+		if commentedOut {
+			d.unreadLines(line)
+			break
+		}
+		if content.Len() == 0 && strings.HasPrefix(line, commentPrefix) {
+			comment = append(comment, uncomment(line))
+			continue
+		}
+		content.WriteString(line)
+		content.WriteString("\n")
+	}
+	res, err := unmarshalResource(content.String())
+	if err != nil {
+		return nil, erratt.Errorf("cannot decode document: %w", err).With("line", start)
+	}
+	if res == nil || len(comment) == 0 {
+		return res, nil
+	}
+	commented := NewResourceWithComment(res)
+	commented.comment = commentText(comment)
+	commented.SetCommentOut(false)
+	return commented, nil
+}
+
+// decodeCommentedDocument decodes a document that is commented out
+// by writeCommentedDocument, optionally preceded by a comment block enclosed in
+// "#" lines.
+func (d *Decoder) decodeCommentedDocument() (resource.Object, error) {
+	start := d.line + 1
+	line, err := d.readLine()
+	if err != nil {
+		return nil, err
+	}
+	var comment []string
+	if line == commentPrefix {
+		comment = []string{}
+		for {
+			line, err = d.readLine()
+			if errors.Is(err, io.EOF) {
+				return nil, erratt.New("unterminated comment block", "line", start)
+			}
+			if err != nil {
+				return nil, err
+			}
+			if line == commentPrefix {
+				break
+			}
+			if !strings.HasPrefix(line, commentPrefix) {
+				return nil, erratt.New("unterminated comment block", "line", start)
+			}
+			comment = append(comment, uncomment(line))
+		}
+		line, err = d.readLine()
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+	}
+	if uncomment(line) != documentStartLine {
+		return nil, erratt.New("comment is not followed by a commented out document", "line", start)
+	}
+	content := &strings.Builder{}
+	for {
+		line, err := d.readLine()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, commentPrefix) {
+			d.unreadLines(line)
+			break
+		}
+		if uncomment(line) == documentEndLine {
+			break
+		}
+		commentedOut, err := d.startsCommentedDocument(line)
+		if err != nil {
+			return nil, err
+		}
+		if commentedOut {
+			d.unreadLines(line)
+			break
+		}
+		content.WriteString(uncomment(line))
+		content.WriteString("\n")
+	}
+	res, err := unmarshalResource(content.String())
+	if err != nil {
+		return nil, erratt.Errorf("cannot decode commented out document: %w", err).With("line", start)
+	}
+	if res == nil {
+		return nil, nil
+	}
+	commented := NewResourceWithComment(res)
+	commented.comment = commentText(comment)
+	return commented, nil
+}
+
+// commentText joins the lines of a comment as AddComment does.
+func commentText(lines []string) *string {
+	text := ""
+	for _, line := range lines {
+		text += line + "\n"
+	}
+	return &text
+}
+
+// unmarshalResource converts a YAML document into an
+// [unstructured.Unstructured]. It returns nil for an empty document.
+func unmarshalResource(content string) (resource.Object, error) {
+	j, err := yaml.YAMLToJSON([]byte(content))
+	if err != nil {
+		return nil, err
+	}
+	// The JSON decoder of apimachinery keeps integers as int64, like
+	// in the content of unstructured resources.
+	obj := map[string]any{}
+	if err := utiljson.Unmarshal(j, &obj); err != nil {
+		return nil, err
+	}
+	if len(obj) == 0 {
+		return nil, nil
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
@@ -0,0 +1,95 @@
+package yaml_test
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("A Decoder", func() {
+	It("reads back the resources written by Marshal", func() {
+		incomplete := newBucket("commented-out")
+		Expect(unstructured.SetNestedField(incomplete.Object, "line 1\nline 2\n", "spec", "note")).To(Succeed())
+		commentedOut := yaml.NewResourceWithComment(incomplete)
+		commentedOut.SetComment("incomplete resource\ndo not apply")
+		annotated := yaml.NewResourceWithComment(newBucket("annotated"))
+		annotated.SetComment("generated")
+		annotated.SetCommentOut(false)
+		written := []resource.Object{newBucket("plain"), commentedOut, annotated}
+
+		sb := &strings.Builder{}
+		for _, res := range written {
+			y, err := yaml.Marshal(res)
+			Expect(err).NotTo(HaveOccurred())
+			sb.WriteString(y)
+		}
+		read, err := decodeAll(sb.String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(HaveLen(3))
+		Expect(read[0]).To(Equal(newBucket("plain")))
+		for i := 1; i < len(written); i++ {
+			Expect(read[i]).To(BeAssignableToTypeOf(&yaml.ResourceWithComment{}))
+			Expect(read[i].(*yaml.ResourceWithComment).Resource()).To(Equal(written[i].(*yaml.ResourceWithComment).Resource()))
+			readComment, readCommentOut := read[i].(*yaml.ResourceWithComment).Comment()
+			writtenComment, writtenCommentOut := written[i].(*yaml.ResourceWithComment).Comment()
+			Expect(readComment).To(Equal(writtenComment))
+			Expect(readCommentOut).To(Equal(writtenCommentOut))
+		}
+	})
+	It("reads documents without markers", func() {
+		read, err := decodeAll("kind: A\n---\nkind: B\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(HaveLen(2))
+		Expect(read[1].GetObjectKind().GroupVersionKind().Kind).To(Equal("B"))
+	})
+	It("ignores the comments before a document start marker", func() {
+		read, err := decodeAll("# exported by test\n---\nkind: A\n...\n#\n# not a comment block\n---\nkind: B\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(HaveLen(2))
+		Expect(read[0]).To(Equal(&unstructured.Unstructured{Object: map[string]any{"kind": "A"}}))
+		Expect(read[1]).To(Equal(&unstructured.Unstructured{Object: map[string]any{"kind": "B"}}))
+	})
+	It("reads comment lines without a document as no resource", func() {
+		read, err := decodeAll("#\n# comment\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(BeEmpty())
+	})
+	DescribeTable("ends a document without end marker at a commented out document",
+		func(input string, comment string) {
+			read, err := decodeAll(input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(read).To(HaveLen(2))
+			Expect(read[0]).To(Equal(&unstructured.Unstructured{Object: map[string]any{"kind": "A"}}))
+			commented, ok := read[1].(*yaml.ResourceWithComment)
+			Expect(ok).To(BeTrue())
+			Expect(commented.Resource()).To(Equal(&unstructured.Unstructured{Object: map[string]any{"kind": "B"}}))
+			readComment, commentOut := commented.Comment()
+			Expect(readComment).To(Equal(comment))
+			Expect(commentOut).To(BeTrue())
+		},
+		Entry("with a comment block", "---\nkind: A\n#\n# incomplete\n#\n# ---\n# kind: B\n# ...\n", "incomplete\n"),
+		Entry("without a comment block", "---\nkind: A\n# ---\n# kind: B\n# ...\n", ""),
+	)
+	It("ends a commented out document without end marker at the next one", func() {
+		read, err := decodeAll("# ---\n# kind: A\n#\n# incomplete\n#\n# ---\n# kind: B\n")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(HaveLen(2))
+		Expect(read[0].(*yaml.ResourceWithComment).Resource()).To(Equal(&unstructured.Unstructured{Object: map[string]any{"kind": "A"}}))
+		comment, _ := read[1].(*yaml.ResourceWithComment).Comment()
+		Expect(comment).To(Equal("incomplete\n"))
+	})
+	It("reports the line of an invalid document", func() {
+		_, err := decodeAll("#\n# comment\n#\n---\nkind: [\n")
+		Expect(err).To(MatchError(ContainSubstring("cannot decode document")))
+		var attrErr interface{ Attrs() []any }
+		Expect(errors.As(err, &attrErr)).To(BeTrue())
+		Expect(attrErr.Attrs()).To(Equal([]any{"line", 5}))
+	})
+})
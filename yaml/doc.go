@@ -5,5 +5,18 @@ Two helpers are provided:
 
   - [Marshal] – plain, indented text, ideal for writing to files.
   - [MarshalPretty] – colored, indented output suited for terminal display.
+
+An [Encoder] writes resources to an [io.Writer] in the format of
+[Marshal], without building a string per resource.
+
+A [Decoder] reads the resources of a YAML stream written by [Marshal],
+including the commented out ones.
+
+A [Normalizer] removes noisy fields, like null values, the status or
+the managed fields, from resources before they are marshalled.
+
+An [Encrypter] encrypts the values of sensitive fields, so that the
+written YAML streams are SOPS documents that can be decrypted with an
+age private key.
 */
 package yaml
@@ -0,0 +1,43 @@
+package yaml
+
+import (
+	"bufio"
+	"io"
+)
+
+// Encoder writes resources as YAML documents to an output stream.
+// Each document is wrapped with "---" and "..." markers, like by
+// [Marshal]. Resources implementing [CommentedYAML] are written with
+// their comment, or commented out.
+//
+// The output is buffered. Call [Encoder.Flush] after the last
+// document to write the buffered data to the underlying writer.
+type Encoder struct {
+	w    *bufio.Writer
+	opts *options
+}
+
+// NewEncoder creates an Encoder that writes to w. If w is a
+// [bufio.Writer], it is used without further buffering.
+func NewEncoder(w io.Writer, opts ...Option) *Encoder {
+	return &Encoder{
+		w:    bufio.NewWriter(w),
+		opts: newOptions(opts),
+	}
+}
+
+// Encode writes the YAML document of resource to the stream. The
+// resource is marshalled completely before it is written, so a
+// resource that cannot be marshalled leaves the stream untouched.
+func (e *Encoder) Encode(resource any) error {
+	b, err := marshal(resource, e.opts)
+	if err != nil {
+		return err
+	}
+	return writeDocument(e.w, resource, b)
+}
+
+// Flush writes the buffered data to the underlying writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
@@ -0,0 +1,503 @@
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SAP/xp-clifford/erratt"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	nodeyaml "go.yaml.in/yaml/v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// sopsMetadataKey is the top-level key of the SOPS metadata.
+	sopsMetadataKey = "sops"
+	// sopsVersion is the SOPS version recorded in the metadata. The
+	// documents can be decrypted by this version and the later ones.
+	sopsVersion = "3.9.0"
+	// dataKeySize is the size of the AES-256 data key.
+	dataKeySize = 32
+	// nonceSize is the size of the AES-GCM nonces used by SOPS.
+	nonceSize = 32
+)
+
+// encryptedValuePattern matches the values encrypted by SOPS.
+var encryptedValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.+),iv:(.+),tag:(.+),type:(.+)\]$`)
+
+// ageKey is the data key encrypted for an age recipient.
+type ageKey struct {
+	Recipient string `yaml:"recipient"`
+	Enc       string `yaml:"enc"`
+}
+
+// sopsMetadata is the SOPS metadata of an encrypted YAML stream.
+type sopsMetadata struct {
+	Age            []ageKey `yaml:"age"`
+	LastModified   string   `yaml:"lastmodified"`
+	MAC            string   `yaml:"mac"`
+	EncryptedRegex string   `yaml:"encrypted_regex"`
+	Version        string   `yaml:"version"`
+}
+
+// Encrypter encrypts the values of sensitive fields of resources, so
+// that the YAML files written by [Marshal] or an [Encoder] are
+// SOPS-compatible documents that can be decrypted with the private
+// key of any of the age recipients:
+//
+//	sops decrypt --input-type yaml --output-type yaml out.yaml
+//
+// SOPS selects the encrypted values by field name, so the value of
+// every field with one of the configured names is encrypted,
+// wherever it is in the resource. Field paths are not supported. The values of maps and lists are
+// encrypted element by element. Null values and empty strings are
+// not encrypted.
+//
+// A YAML stream becomes a valid SOPS document when it is sealed by
+// [Encrypter.Seal], which adds the SOPS metadata to its first
+// document.
+type Encrypter struct {
+	regex      *regexp.Regexp
+	dataKey    []byte
+	recipients []ageKey
+	fields     []string
+	mu         sync.Mutex
+	// found holds the names of the fields whose values have been
+	// encrypted.
+	found map[string]bool
+}
+
+// NewEncrypter creates an Encrypter that encrypts the values of the
+// fields with the given names for the given age recipients, like
+// "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p".
+// Field names that contain a dot are rejected, as they look like
+// paths, which would match no field.
+func NewEncrypter(recipients []string, fields ...string) (*Encrypter, error) {
+	if len(recipients) == 0 {
+		return nil, erratt.New("no age recipient is configured")
+	}
+	if len(fields) == 0 {
+		return nil, erratt.New("no field to encrypt is configured")
+	}
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		if field == "" || strings.Contains(field, ".") {
+			return nil, erratt.New("field to encrypt must be a field name, not a path", "field", field)
+		}
+		names[i] = regexp.QuoteMeta(field)
+	}
+	e := &Encrypter{
+		regex:   regexp.MustCompile("^(" + strings.Join(names, "|") + ")$"),
+		dataKey: make([]byte, dataKeySize),
+		fields:  slices.Clone(fields),
+		found:   map[string]bool{},
+	}
+	if _, err := rand.Read(e.dataKey); err != nil {
+		return nil, erratt.Errorf("cannot generate data key: %w", err)
+	}
+	for _, recipient := range recipients {
+		enc, err := encryptDataKey(recipient, e.dataKey)
+		if err != nil {
+			return nil, err
+		}
+		e.recipients = append(e.recipients, ageKey{Recipient: recipient, Enc: enc})
+	}
+	return e, nil
+}
+
+// encryptDataKey returns the armored age encryption of dataKey for
+// recipient.
+func encryptDataKey(recipient string, dataKey []byte) (string, error) {
+	r, err := age.ParseX25519Recipient(recipient)
+	if err != nil {
+		return "", erratt.Errorf("invalid age recipient: %w", err).With("recipient", recipient)
+	}
+	buf := &bytes.Buffer{}
+	aw := armor.NewWriter(buf)
+	w, err := age.Encrypt(aw, r)
+	if err != nil {
+		return "", erratt.Errorf("cannot encrypt data key: %w", err).With("recipient", recipient)
+	}
+	if _, err := w.Write(dataKey); err != nil {
+		return "", erratt.Errorf("cannot encrypt data key: %w", err).With("recipient", recipient)
+	}
+	if err := w.Close(); err != nil {
+		return "", erratt.Errorf("cannot encrypt data key: %w", err).With("recipient", recipient)
+	}
+	if err := aw.Close(); err != nil {
+		return "", erratt.Errorf("cannot encrypt data key: %w", err).With("recipient", recipient)
+	}
+	return buf.String(), nil
+}
+
+// isEncrypted reports whether the values under the field with the
+// given name are encrypted.
+func (e *Encrypter) isEncrypted(name string) bool {
+	return e.regex.MatchString(name)
+}
+
+// setFound records that a value of the field with the given name has
+// been encrypted.
+func (e *Encrypter) setFound(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.found[name] = true
+}
+
+// UnusedFields returns the configured fields of which no value has
+// been encrypted by [Encrypter.EncryptResource] so far, like
+// misspelled field names.
+func (e *Encrypter) UnusedFields() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var unused []string
+	for _, field := range e.fields {
+		if !e.found[field] && !slices.Contains(unused, field) {
+			unused = append(unused, field)
+		}
+	}
+	return unused
+}
+
+// additionalData returns the additional data that authenticates the
+// position of a value encrypted by SOPS: the field names of its path
+// joined by colons.
+func additionalData(path []string) string {
+	return strings.Join(path, ":") + ":"
+}
+
+// encrypt returns the SOPS representation of plaintext of the given
+// type, encrypted with the data key.
+func (e *Encrypter) encrypt(plaintext []byte, typ, additionalData string) (string, error) {
+	block, err := aes.NewCipher(e.dataKey)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, nonceSize)
+	if err != nil {
+		return "", err
+	}
+	iv := make([]byte, nonceSize)
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	out := gcm.Seal(nil, iv, plaintext, []byte(additionalData))
+	tag := len(out) - gcm.Overhead()
+	return fmt.Sprintf("ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:%s]",
+		base64.StdEncoding.EncodeToString(out[:tag]),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(out[tag:]),
+		typ), nil
+}
+
+// decrypt returns the plaintext of a value encrypted by encrypt.
+func (e *Encrypter) decrypt(value, additionalData string) (any, error) {
+	m := encryptedValuePattern.FindStringSubmatch(value)
+	if m == nil {
+		return nil, erratt.New("value is not encrypted")
+	}
+	var parts [3][]byte
+	for i := range parts {
+		b, err := base64.StdEncoding.DecodeString(m[i+1])
+		if err != nil {
+			return nil, err
+		}
+		parts[i] = b
+	}
+	data, iv, tag := parts[0], parts[1], parts[2]
+	block, err := aes.NewCipher(e.dataKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+	switch typ := m[4]; typ {
+	case "str":
+		return string(plaintext), nil
+	case "int":
+		return strconv.Atoi(string(plaintext))
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	default:
+		return nil, erratt.New("unsupported type of encrypted value", "type", typ)
+	}
+}
+
+// scalarBytes returns the representation of a scalar value that SOPS
+// encrypts and authenticates, and the name of its type.
+func scalarBytes(v any) ([]byte, string, error) {
+	switch v := v.(type) {
+	case string:
+		return []byte(v), "str", nil
+	case int:
+		return []byte(strconv.Itoa(v)), "int", nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), "int", nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64)), "float", nil
+	case bool:
+		// SOPS encodes booleans in title case
+		if v {
+			return []byte("True"), "bool", nil
+		}
+		return []byte("False"), "bool", nil
+	}
+	return nil, "", erratt.New("unsupported value type", "type", fmt.Sprintf("%T", v))
+}
+
+// encryptValue encrypts the scalar values of v that are below a field
+// to be encrypted. path holds the field names leading to v, encrypted
+// whether one of them is to be encrypted.
+func (e *Encrypter) encryptValue(v any, path []string, encrypted bool) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if child != nil && child != "" && e.isEncrypted(key) {
+				e.setFound(key)
+			}
+			value, err := e.encryptValue(child, append(path, key), encrypted || e.isEncrypted(key))
+			if err != nil {
+				return nil, err
+			}
+			v[key] = value
+		}
+		return v, nil
+	case []any:
+		// list elements share the path of the list
+		for i, child := range v {
+			value, err := e.encryptValue(child, path, encrypted)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = value
+		}
+		return v, nil
+	case nil:
+		return nil, nil
+	}
+	if !encrypted || v == "" {
+		return v, nil
+	}
+	plaintext, typ, err := scalarBytes(v)
+	if err != nil {
+		return nil, erratt.Errorf("cannot encrypt field: %w", err).With("field", strings.Join(path, "."))
+	}
+	return e.encrypt(plaintext, typ, additionalData(path))
+}
+
+// hasEncryptedAncestor reports whether a field below the field path
+// is to be encrypted. Comments at such positions would be taken for
+// encrypted values by SOPS.
+func (e *Encrypter) hasEncryptedAncestor(path string) bool {
+	segments := splitPath(path)
+	return slices.ContainsFunc(segments[:max(len(segments)-1, 0)], e.isEncrypted)
+}
+
+// EncryptResource returns a copy of res with the values of the
+// sensitive fields encrypted. The copy is an
+// [unstructured.Unstructured]. If res is a [ResourceWithComment], the
+// wrapped resource is encrypted and the comment is kept, also if the
+// resource is commented out.
+//
+// Field comments below an encrypted field are dropped, and the fields
+// below an encrypted field that are to be commented out are removed,
+// because SOPS cannot tell such comments from encrypted values.
+func (e *Encrypter) EncryptResource(res resource.Object) (resource.Object, error) {
+	if r, ok := res.(*ResourceWithComment); ok {
+		content, err := copyContent(r.Resource())
+		if err != nil {
+			return nil, err
+		}
+		commented := NewResourceWithComment(nil)
+		commented.CloneComment(r)
+		for path, comment := range r.FieldComments() {
+			if !e.hasEncryptedAncestor(path) {
+				commented.AddFieldComment(path, comment)
+			}
+		}
+		for _, path := range r.CommentedOutFields() {
+			if e.hasEncryptedAncestor(path) {
+				removePath(content, splitPath(path))
+			} else {
+				commented.CommentOutField(path)
+			}
+		}
+		encrypted, err := e.encryptContent(content)
+		if err != nil {
+			return nil, err
+		}
+		commented.SetResource(encrypted)
+		return commented, nil
+	}
+	content, err := copyContent(res)
+	if err != nil {
+		return nil, err
+	}
+	return e.encryptContent(content)
+}
+
+func (e *Encrypter) encryptContent(content map[string]any) (resource.Object, error) {
+	if _, err := e.encryptValue(content, nil, false); err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// hashNode adds the values of the YAML node tree to the MAC h in the
+// order SOPS reads them. The encrypted values are decrypted first.
+func (e *Encrypter) hashNode(h hash.Hash, node *nodeyaml.Node, path []string, encrypted bool) error {
+	switch node.Kind {
+	case nodeyaml.DocumentNode, nodeyaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := e.hashNode(h, child, path, encrypted); err != nil {
+				return err
+			}
+		}
+	case nodeyaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if len(path) == 0 && key == sopsMetadataKey {
+				continue
+			}
+			if err := e.hashNode(h, node.Content[i+1], append(path, key), encrypted || e.isEncrypted(key)); err != nil {
+				return err
+			}
+		}
+	case nodeyaml.AliasNode:
+		return e.hashNode(h, node.Alias, path, encrypted)
+	case nodeyaml.ScalarNode:
+		var v any
+		if err := node.Decode(&v); err != nil {
+			return err
+		}
+		if s, ok := v.(string); ok && encrypted && s != "" {
+			var err error
+			if v, err = e.decrypt(s, additionalData(path)); err != nil {
+				return erratt.Errorf("cannot decrypt field: %w", err).With("field", strings.Join(path, "."), "line", node.Line)
+			}
+		}
+		if v == nil {
+			return nil
+		}
+		b, _, err := scalarBytes(v)
+		if err != nil {
+			return erratt.Errorf("cannot authenticate field: %w", err).With("field", strings.Join(path, "."), "line", node.Line)
+		}
+		h.Write(b)
+	}
+	return nil
+}
+
+// mac returns the message authentication code of the YAML stream r,
+// computed like SOPS does over the values of all documents.
+func (e *Encrypter) mac(r io.Reader) (string, error) {
+	h := sha512.New()
+	dec := nodeyaml.NewDecoder(r)
+	for {
+		doc := &nodeyaml.Node{}
+		err := dec.Decode(doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", erratt.Errorf("cannot parse encrypted YAML: %w", err)
+		}
+		if err := e.hashNode(h, doc, nil, false); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%X", h.Sum(nil)), nil
+}
+
+// metadata returns the YAML representation of the SOPS metadata of a
+// stream with the given MAC.
+func (e *Encrypter) metadata(mac string, lastModified time.Time) ([]byte, error) {
+	modified := lastModified.UTC().Format(time.RFC3339)
+	encryptedMAC, err := e.encrypt([]byte(mac), "str", modified)
+	if err != nil {
+		return nil, err
+	}
+	node := &nodeyaml.Node{}
+	if err := node.Encode(map[string]sopsMetadata{
+		sopsMetadataKey: {
+			Age:            e.recipients,
+			LastModified:   modified,
+			MAC:            encryptedMAC,
+			EncryptedRegex: e.regex.String(),
+			Version:        sopsVersion,
+		},
+	}); err != nil {
+		return nil, err
+	}
+	return encodeNode(node)
+}
+
+// Seal copies the YAML stream r, whose resources have been encrypted
+// by [Encrypter.EncryptResource], to w and adds the SOPS metadata to
+// its first document. The metadata contains the MAC of all values of
+// the stream, so no document can be added to a sealed stream. If the
+// stream has no document that is not commented out, the metadata is
+// written as a separate document.
+func (e *Encrypter) Seal(r io.ReadSeeker, w io.Writer) error {
+	mac, err := e.mac(r)
+	if err != nil {
+		return err
+	}
+	metadata, err := e.metadata(mac, time.Now())
+	if err != nil {
+		return erratt.Errorf("cannot create SOPS metadata: %w", err)
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	sw := &stickyWriter{w: w}
+	br := bufio.NewReader(r)
+	sealed := false
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+		if !sealed && strings.TrimSuffix(line, "\n") == documentEndLine {
+			// the metadata closes the first document
+			_, _ = sw.Write(metadata)
+			sealed = true
+		}
+		_, _ = io.WriteString(sw, line)
+		if err != nil {
+			break
+		}
+	}
+	if !sealed {
+		_, _ = io.WriteString(sw, documentStart)
+		_, _ = sw.Write(metadata)
+		_, _ = io.WriteString(sw, documentEnd)
+	}
+	return sw.err
+}
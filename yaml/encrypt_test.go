@@ -0,0 +1,219 @@
+package yaml_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var encryptedValue = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+// decryptValue decrypts a value in SOPS format with dataKey.
+func decryptValue(value string, dataKey []byte, additionalData string) string {
+	m := encryptedValue.FindStringSubmatch(value)
+	ExpectWithOffset(1, m).NotTo(BeNil())
+	decode := func(s string) []byte {
+		b, err := base64.StdEncoding.DecodeString(s)
+		ExpectWithOffset(2, err).NotTo(HaveOccurred())
+		return b
+	}
+	data, iv, tag := decode(m[1]), decode(m[2]), decode(m[3])
+	block, err := aes.NewCipher(dataKey)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), []byte(additionalData))
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return string(plaintext)
+}
+
+// dataKey decrypts the data key of the SOPS metadata of res.
+func dataKey(res resource.Object, identity *age.X25519Identity) []byte {
+	u := res.(*unstructured.Unstructured)
+	keys, found, err := unstructured.NestedSlice(u.Object, "sops", "age")
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	ExpectWithOffset(1, found).To(BeTrue())
+	ExpectWithOffset(1, keys).To(HaveLen(1))
+	key := keys[0].(map[string]any)
+	ExpectWithOffset(1, key["recipient"]).To(Equal(identity.Recipient().String()))
+	r, err := age.Decrypt(armor.NewReader(strings.NewReader(key["enc"].(string))), identity)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	b, err := io.ReadAll(r)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	return b
+}
+
+var _ = Describe("Encrypter", func() {
+	var identity *age.X25519Identity
+	var encrypter *yaml.Encrypter
+
+	BeforeEach(func() {
+		var err error
+		identity, err = age.GenerateX25519Identity()
+		Expect(err).NotTo(HaveOccurred())
+		encrypter, err = yaml.NewEncrypter([]string{identity.Recipient().String()}, "password", "credentials")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("requires recipients and fields", func() {
+		_, err := yaml.NewEncrypter(nil, "password")
+		Expect(err).To(MatchError(ContainSubstring("no age recipient")))
+		_, err = yaml.NewEncrypter([]string{identity.Recipient().String()})
+		Expect(err).To(MatchError(ContainSubstring("no field to encrypt")))
+		_, err = yaml.NewEncrypter([]string{"age1invalid"}, "password")
+		Expect(err).To(MatchError(ContainSubstring("invalid age recipient")))
+	})
+
+	It("rejects field paths", func() {
+		_, err := yaml.NewEncrypter([]string{identity.Recipient().String()}, "password", "spec.forProvider.password")
+		Expect(err).To(MatchError(ContainSubstring("must be a field name, not a path")))
+	})
+
+	It("reports the fields of which no value has been encrypted", func() {
+		e, err := yaml.NewEncrypter([]string{identity.Recipient().String()}, "password", "empty", "token")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(e.UnusedFields()).To(Equal([]string{"password", "empty", "token"}))
+		_, err = e.EncryptResource(newSecret("db"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(e.UnusedFields()).To(Equal([]string{"empty", "token"}))
+	})
+
+	It("encrypts the values of the configured fields only", func() {
+		res := newSecret("db")
+		encrypted, err := encrypter.EncryptResource(res)
+		Expect(err).NotTo(HaveOccurred())
+		spec := encrypted.(*unstructured.Unstructured).Object["spec"].(map[string]any)
+		Expect(spec["user"]).To(Equal("admin"))
+		Expect(spec["empty"]).To(Equal(""))
+		Expect(spec["password"]).To(MatchRegexp(encryptedValue.String()))
+		credentials := spec["credentials"].(map[string]any)
+		Expect(credentials["port"]).To(HaveSuffix(",type:int]"))
+		Expect(credentials["tokens"]).To(HaveEach(MatchRegexp(encryptedValue.String())))
+		By("leaving the original resource untouched")
+		Expect(res.Object["spec"].(map[string]any)["password"]).To(Equal("s3cret"))
+	})
+
+	It("drops the field comments below encrypted fields", func() {
+		res := yaml.NewResourceWithComment(newSecret("db"))
+		res.SetComment("commented out")
+		res.AddFieldComment("spec.password", "kept")
+		res.AddFieldComment("spec.credentials.port", "dropped")
+		res.CommentOutField("spec.credentials.tokens")
+		encrypted, err := encrypter.EncryptResource(res)
+		Expect(err).NotTo(HaveOccurred())
+		commented, ok := encrypted.(*yaml.ResourceWithComment)
+		Expect(ok).To(BeTrue())
+		comment, commentedOut := commented.Comment()
+		Expect(comment).To(Equal("commented out\n"))
+		Expect(commentedOut).To(BeTrue())
+		Expect(commented.FieldComments()).To(Equal(map[string]string{"spec.password": "kept"}))
+		Expect(commented.CommentedOutFields()).To(BeEmpty())
+		u := commented.Resource().(*unstructured.Unstructured)
+		Expect(u.Object["spec"].(map[string]any)["credentials"]).NotTo(HaveKey("tokens"))
+	})
+
+	It("seals a stream with the SOPS metadata in the first document", func() {
+		buf := &bytes.Buffer{}
+		encoder := yaml.NewEncoder(buf)
+		for _, name := range []string{"first", "second"} {
+			encrypted, err := encrypter.EncryptResource(newSecret(name))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(encoder.Encode(encrypted)).To(Succeed())
+		}
+		Expect(encoder.Flush()).To(Succeed())
+		sealed := &bytes.Buffer{}
+		Expect(encrypter.Seal(bytes.NewReader(buf.Bytes()), sealed)).To(Succeed())
+
+		resources, err := decodeAll(sealed.String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resources).To(HaveLen(2))
+		Expect(resources[1].(*unstructured.Unstructured).Object).NotTo(HaveKey("sops"))
+		first := resources[0].(*unstructured.Unstructured)
+		Expect(first.GetName()).To(Equal("first"))
+		Expect(first.Object["sops"]).To(HaveKeyWithValue("encrypted_regex", "^(password|credentials)$"))
+		Expect(first.Object["sops"]).To(HaveKey("lastmodified"))
+
+		key := dataKey(first, identity)
+		Expect(key).To(HaveLen(32))
+		password := first.Object["spec"].(map[string]any)["password"].(string)
+		Expect(decryptValue(password, key, "spec:password:")).To(Equal("s3cret"))
+		sops := first.Object["sops"].(map[string]any)
+		mac := decryptValue(sops["mac"].(string), key, sops["lastmodified"].(string))
+		Expect(mac).To(MatchRegexp("^[0-9A-F]{128}$"))
+	})
+
+	Describe("decrypting the sealed stream with SOPS", func() {
+		var sealed *bytes.Buffer
+		// sopsDecrypt decrypts the YAML stream with the sops command
+		// line client and returns its output.
+		sopsDecrypt := func(stream string) (string, error) {
+			path := filepath.Join(GinkgoT().TempDir(), "out.yaml")
+			ExpectWithOffset(1, os.WriteFile(path, []byte(stream), 0o600)).To(Succeed())
+			cmd := exec.Command("sops", "decrypt", "--input-type", "yaml", "--output-type", "yaml", path)
+			cmd.Env = append(os.Environ(), "SOPS_AGE_KEY="+identity.String(), "SOPS_AGE_KEY_FILE=")
+			out, err := cmd.CombinedOutput()
+			return string(out), err
+		}
+
+		BeforeEach(func() {
+			if _, err := exec.LookPath("sops"); err != nil {
+				Skip("sops is not installed")
+			}
+			buf := &bytes.Buffer{}
+			encoder := yaml.NewEncoder(buf)
+			for _, name := range []string{"first", "second"} {
+				encrypted, err := encrypter.EncryptResource(newSecret(name))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(encoder.Encode(encrypted)).To(Succeed())
+			}
+			Expect(encoder.Flush()).To(Succeed())
+			sealed = &bytes.Buffer{}
+			Expect(encrypter.Seal(bytes.NewReader(buf.Bytes()), sealed)).To(Succeed())
+		})
+
+		It("restores the plain resources", func() {
+			plain, err := sopsDecrypt(sealed.String())
+			Expect(err).NotTo(HaveOccurred(), plain)
+			resources, err := decodeAll(plain)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(resources).To(Equal([]resource.Object{newSecret("first"), newSecret("second")}))
+		})
+
+		It("detects a modified value by the MAC", func() {
+			out, err := sopsDecrypt(strings.Replace(sealed.String(), "user: admin", "user: root", 1))
+			Expect(err).To(HaveOccurred())
+			Expect(out).To(ContainSubstring("MAC mismatch"))
+		})
+	})
+
+	It("writes the metadata as a separate document if all documents are commented out", func() {
+		res := yaml.NewResourceWithComment(newSecret("db"))
+		res.SetComment("commented out")
+		encrypted, err := encrypter.EncryptResource(res)
+		Expect(err).NotTo(HaveOccurred())
+		y, err := yaml.Marshal(encrypted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(y).NotTo(ContainSubstring("s3cret"))
+		sealed := &bytes.Buffer{}
+		Expect(encrypter.Seal(strings.NewReader(y), sealed)).To(Succeed())
+		Expect(sealed.String()).To(HavePrefix(y + "---\nsops:\n"))
+		Expect(sealed.String()).To(HaveSuffix("\n...\n"))
+	})
+})
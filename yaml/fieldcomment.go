@@ -0,0 +1,196 @@
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SAP/xp-clifford/erratt"
+
+	nodeyaml "go.yaml.in/yaml/v3"
+)
+
+// fieldRef locates a field in a YAML node tree.
+type fieldRef struct {
+	// parent is the mapping or sequence node that contains the field.
+	parent *nodeyaml.Node
+	// index is the position of the key of the field in a mapping
+	// parent, or of the element in a sequence parent.
+	index int
+	// up locates the parent, if it is the value of a mapping field.
+	up *fieldRef
+}
+
+// findField returns the location of the field identified by path in
+// the node tree root.
+func findField(root *nodeyaml.Node, path []string) (*fieldRef, bool) {
+	ref := &fieldRef{parent: root}
+	for i, segment := range path {
+		node := ref.parent
+		index := -1
+		switch node.Kind {
+		case nodeyaml.MappingNode:
+			for j := 0; j+1 < len(node.Content); j += 2 {
+				if node.Content[j].Value == segment {
+					index = j
+					break
+				}
+			}
+		case nodeyaml.SequenceNode:
+			if j, err := strconv.Atoi(segment); err == nil && j >= 0 && j < len(node.Content) {
+				index = j
+			}
+		}
+		if index < 0 {
+			return nil, false
+		}
+		ref.index = index
+		if i == len(path)-1 {
+			return ref, true
+		}
+		if node.Kind == nodeyaml.MappingNode {
+			ref = &fieldRef{parent: node.Content[index+1], up: ref}
+		} else {
+			ref = &fieldRef{parent: node.Content[index]}
+		}
+	}
+	return nil, false
+}
+
+// addFieldComment places comment on the line of the field referenced
+// by ref.
+func addFieldComment(ref *fieldRef, comment string) {
+	node := ref.parent.Content[ref.index]
+	if ref.parent.Kind == nodeyaml.SequenceNode && len(node.Content) > 0 {
+		// the comment of a list element is placed on its first line
+		node = node.Content[0]
+	}
+	node.LineComment = "# " + strings.Join(strings.Split(comment, "\n"), " ")
+}
+
+// appendComment appends the comment text to a head or foot comment.
+func appendComment(existing, text string) string {
+	if existing == "" {
+		return text
+	}
+	return existing + "\n" + text
+}
+
+// commentOutField removes the mapping field referenced by ref from
+// the node tree and places its YAML representation as a comment at
+// its position.
+func commentOutField(ref *fieldRef) error {
+	parent := ref.parent
+	if parent.Kind != nodeyaml.MappingNode {
+		// list elements cannot be commented out without changing
+		// the positions of the following elements
+		return nil
+	}
+	pair := &nodeyaml.Node{
+		Kind:    nodeyaml.MappingNode,
+		Content: slices.Clone(parent.Content[ref.index : ref.index+2]),
+	}
+	parent.Content = slices.Delete(parent.Content, ref.index, ref.index+2)
+	for len(ref.parent.Content) == 0 && ref.up != nil {
+		// The parent is left empty, so it is commented out with
+		// the field. Otherwise, uncommenting the field would
+		// duplicate the key of the parent.
+		up := ref.up
+		key := *up.parent.Content[up.index]
+		key.HeadComment, key.LineComment, key.FootComment = "", "", ""
+		pair = &nodeyaml.Node{
+			Kind:    nodeyaml.MappingNode,
+			Content: []*nodeyaml.Node{&key, pair},
+		}
+		up.parent.Content = slices.Delete(up.parent.Content, up.index, up.index+2)
+		ref = up
+	}
+	b, err := encodeNode(pair)
+	if err != nil {
+		return err
+	}
+	placeComment(ref.parent, ref.index, commentLines(string(b)))
+	return nil
+}
+
+// placeComment places text as a comment before the field at position
+// index of the mapping parent, or after the last field.
+func placeComment(parent *nodeyaml.Node, index int, text string) {
+	switch {
+	case index < len(parent.Content):
+		next := parent.Content[index]
+		next.HeadComment = appendComment(next.HeadComment, text)
+	case index > 0:
+		prev := parent.Content[index-2]
+		prev.FootComment = appendComment(prev.FootComment, text)
+	default:
+		parent.HeadComment = appendComment(parent.HeadComment, text)
+	}
+}
+
+// commentLines prefixes each line of text with "# ".
+func commentLines(text string) string {
+	sb := &strings.Builder{}
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(sb, "# %s", scanner.Text())
+	}
+	return sb.String()
+}
+
+// encodeNode encodes node with the indentation of sigs.k8s.io/yaml.
+func encodeNode(node *nodeyaml.Node) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := nodeyaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	enc.CompactSeqIndent()
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// hasFieldComments reports whether r has any field comment or
+// commented out field.
+func hasFieldComments(r FieldCommentedYAML) bool {
+	return len(r.FieldComments()) > 0 || len(r.CommentedOutFields()) > 0
+}
+
+// applyFieldComments adds the field comments of r to the YAML node
+// tree root and comments out the fields of r that are to be
+// commented out.
+func applyFieldComments(root *nodeyaml.Node, r FieldCommentedYAML) error {
+	comments := r.FieldComments()
+	for _, path := range slices.Sorted(maps.Keys(comments)) {
+		if ref, ok := findField(root, splitPath(path)); ok {
+			addFieldComment(ref, comments[path])
+		}
+	}
+	// The fields closer to the root are commented out first, so
+	// that their commented out descendants are not commented twice.
+	commentedOut := slices.Clone(r.CommentedOutFields())
+	slices.SortStableFunc(commentedOut, func(a, b string) int {
+		return cmp.Compare(len(splitPath(a)), len(splitPath(b)))
+	})
+	for _, path := range commentedOut {
+		ref, ok := findField(root, splitPath(path))
+		if !ok {
+			continue
+		}
+		if err := commentOutField(ref); err != nil {
+			return erratt.Errorf("cannot comment out field: %w", err).With("field", path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package yaml
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+)
+
+// wildcard is the field path segment that matches every key of a map
+// and every element of a list.
+const wildcard = "*"
+
+// Normalizer removes noisy fields from resources, so that the YAML
+// representation is minimal and reviewable.
+//
+// Fields are identified by dot-separated paths, like
+// "metadata.annotations". The segment "*" matches every key of a
+// map and every element of a list. A dot that is part of a key is
+// escaped with a backslash, like
+// "metadata.annotations.kubectl\.kubernetes\.io/last-applied-configuration".
+type Normalizer struct {
+	removeEmpty bool
+	paths       [][]string
+	kindPaths   map[string][][]string
+}
+
+// NewNormalizer creates a Normalizer that does not remove any field.
+func NewNormalizer() *Normalizer {
+	return &Normalizer{
+		kindPaths: map[string][][]string{},
+	}
+}
+
+// DefaultNormalizer creates a Normalizer that removes the null and
+// empty values, the status and the managed fields of resources.
+func DefaultNormalizer() *Normalizer {
+	return NewNormalizer().
+		WithRemoveEmpty(true).
+		WithRemovedPaths("status", "metadata.managedFields")
+}
+
+// WithRemoveEmpty sets whether null values, empty strings, empty
+// maps and empty lists are removed. Maps and lists that become empty
+// by the removal are removed too. List elements are kept, so that
+// the positions of the remaining elements do not change.
+func (n *Normalizer) WithRemoveEmpty(removeEmpty bool) *Normalizer {
+	n.removeEmpty = removeEmpty
+	return n
+}
+
+// WithRemovedPaths adds field paths that are removed from resources
+// of every kind.
+func (n *Normalizer) WithRemovedPaths(paths ...string) *Normalizer {
+	for _, path := range paths {
+		n.paths = append(n.paths, splitPath(path))
+	}
+	return n
+}
+
+// WithRemovedKindPaths adds field paths that are removed from the
+// resources of the given kind only.
+func (n *Normalizer) WithRemovedKindPaths(kind string, paths ...string) *Normalizer {
+	for _, path := range paths {
+		n.kindPaths[kind] = append(n.kindPaths[kind], splitPath(path))
+	}
+	return n
+}
+
+// splitPath splits a field path at the unescaped dots. Empty
+// segments are dropped.
+func splitPath(path string) []string {
+	segments := []string{}
+	sb := &strings.Builder{}
+	flush := func() {
+		if sb.Len() > 0 {
+			segments = append(segments, sb.String())
+		}
+		sb.Reset()
+	}
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			sb.WriteByte('.')
+			i++
+		case path[i] == '.':
+			flush()
+		default:
+			sb.WriteByte(path[i])
+		}
+	}
+	flush()
+	return segments
+}
+
+// Normalize removes the configured fields from the content of a
+// resource of the given kind in place.
+func (n *Normalizer) Normalize(kind string, content map[string]any) {
+	for _, path := range n.paths {
+		removePath(content, path)
+	}
+	for _, path := range n.kindPaths[kind] {
+		removePath(content, path)
+	}
+	if n.removeEmpty {
+		removeEmpty(content)
+	}
+}
+
+// NormalizeResource returns a normalized copy of res. The copy is an
+// [unstructured.Unstructured]. If res is a [ResourceWithComment], the
+// wrapped resource is normalized and the comment is kept.
+func (n *Normalizer) NormalizeResource(res resource.Object) (resource.Object, error) {
+	if r, ok := res.(*ResourceWithComment); ok {
+		normalized, err := n.NormalizeResource(r.Resource())
+		if err != nil {
+			return nil, err
+		}
+		commented := NewResourceWithComment(normalized)
+		commented.CloneComment(r)
+		commented.CloneFieldComments(r)
+		return commented, nil
+	}
+	content, err := copyContent(res)
+	if err != nil {
+		return nil, err
+	}
+	n.Normalize(res.GetObjectKind().GroupVersionKind().Kind, content)
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// copyContent returns a copy of the content of res as a
+// JSON-compatible map.
+func copyContent(res resource.Object) (map[string]any, error) {
+	u, ok := res.(runtime.Unstructured)
+	if !ok {
+		return runtime.DefaultUnstructuredConverter.ToUnstructured(res)
+	}
+	// Unstructured content is copied by a JSON round trip, because
+	// it may contain values of non-JSON types, like int.
+	b, err := json.Marshal(u.UnstructuredContent())
+	if err != nil {
+		return nil, err
+	}
+	content := map[string]any{}
+	if err := utiljson.Unmarshal(b, &content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// removePath removes the field identified by path from v.
+func removePath(v any, path []string) {
+	if len(path) == 0 {
+		return
+	}
+	segment, rest := path[0], path[1:]
+	switch v := v.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			if segment == wildcard {
+				clear(v)
+			} else {
+				delete(v, segment)
+			}
+			return
+		}
+		if segment == wildcard {
+			for _, child := range v {
+				removePath(child, rest)
+			}
+			return
+		}
+		if child, ok := v[segment]; ok {
+			removePath(child, rest)
+		}
+	case []any:
+		// list elements are not removed, only fields of the
+		// elements
+		if segment == wildcard && len(rest) > 0 {
+			for _, child := range v {
+				removePath(child, rest)
+			}
+		}
+	}
+}
+
+// isEmpty reports whether v is null, an empty string, an empty map
+// or an empty list.
+func isEmpty(v any) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	}
+	return false
+}
+
+// removeEmpty removes the empty values from v recursively.
+func removeEmpty(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for key, child := range v {
+			removeEmpty(child)
+			if isEmpty(child) {
+				delete(v, key)
+			}
+		}
+	case []any:
+		for _, child := range v {
+			removeEmpty(child)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package yaml_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+)
+
+var _ = Describe("A Normalizer", func() {
+	var content map[string]any
+	BeforeEach(func() {
+		content = newNoisyBucket("bucket").Object
+	})
+	It("does not change the content by default", func() {
+		yaml.NewNormalizer().Normalize("Bucket", content)
+		Expect(content).To(Equal(newNoisyBucket("bucket").Object))
+	})
+	Describe("by default configuration", func() {
+		BeforeEach(func() {
+			yaml.DefaultNormalizer().Normalize("Bucket", content)
+		})
+		It("removes the status and the managed fields", func() {
+			Expect(content).NotTo(HaveKey("status"))
+			Expect(content["metadata"]).NotTo(HaveKey("managedFields"))
+		})
+		It("removes null and empty values", func() {
+			Expect(content["metadata"]).NotTo(HaveKey("creationTimestamp"))
+			Expect(content["metadata"]).NotTo(HaveKey("labels"))
+			Expect(content["spec"]).NotTo(HaveKey("empty"))
+		})
+		It("keeps zero numbers and list elements", func() {
+			Expect(content["spec"]).To(HaveKeyWithValue("replicas", int64(0)))
+			Expect(content["spec"]).To(HaveKeyWithValue("tags", []any{map[string]any{"key": "a"}}))
+		})
+	})
+	It("removes paths with escaped dots", func() {
+		yaml.NewNormalizer().WithRemovedPaths(`metadata.annotations.example\.com/id`).Normalize("Bucket", content)
+		Expect(content["metadata"]).To(HaveKeyWithValue("annotations", map[string]any{"team": "core"}))
+	})
+	It("removes paths with wildcards", func() {
+		yaml.NewNormalizer().WithRemovedPaths("spec.tags.*.value").Normalize("Bucket", content)
+		Expect(content["spec"]).To(HaveKeyWithValue("tags", []any{map[string]any{"key": "a"}}))
+	})
+	It("removes kind paths from the resources of the kind only", func() {
+		n := yaml.NewNormalizer().WithRemovedKindPaths("User", "spec")
+		n.Normalize("Bucket", content)
+		Expect(content).To(HaveKey("spec"))
+		n.Normalize("User", content)
+		Expect(content).NotTo(HaveKey("spec"))
+	})
+})
@@ -0,0 +1,75 @@
+package yaml
+
+import "github.com/charmbracelet/glamour"
+
+// DefaultStyle is the glamour style used by [MarshalPretty] unless
+// another style is set with [WithStyle].
+const DefaultStyle = "dracula"
+
+// options holds the settings of the YAML marshalling.
+type options struct {
+	canonicalOrder bool
+	literalBlocks  bool
+	foldWidth      int
+	style          string
+}
+
+// Option configures how resources are marshalled to YAML.
+type Option func(*options)
+
+// WithCanonicalOrder emits the apiVersion, kind, metadata and spec
+// keys of a resource first, followed by the remaining top-level keys
+// in alphabetical order, as manifests are usually written by hand.
+// Without this option, all keys are emitted in alphabetical order.
+func WithCanonicalOrder() Option {
+	return func(o *options) {
+		o.canonicalOrder = true
+	}
+}
+
+// WithLiteralBlocks renders strings that span multiple lines, like
+// certificates, scripts or JSON documents, as literal block scalars
+// ("|"). Strings that YAML cannot represent as block scalars, like
+// strings with trailing spaces on a line, stay double-quoted.
+func WithLiteralBlocks() Option {
+	return func(o *options) {
+		o.literalBlocks = true
+	}
+}
+
+// WithFoldWidth renders single-line strings that do not fit into
+// lines of width characters as folded block scalars (">-"), whose
+// lines are broken at spaces. The value of the strings does not
+// change. A width of zero or less disables folding.
+func WithFoldWidth(width int) Option {
+	return func(o *options) {
+		o.foldWidth = width
+	}
+}
+
+// WithStyle sets the glamour style of the syntax highlighting of
+// [MarshalPretty]. The style is either the name of a standard glamour
+// style, like "dark", "light" or "dracula", or the path of a JSON
+// style file. It has no effect on [Marshal] and [Encoder].
+func WithStyle(style string) Option {
+	return func(o *options) {
+		o.style = style
+	}
+}
+
+// ValidateStyle returns an error if style is neither a standard
+// glamour style nor a readable glamour style file.
+func ValidateStyle(style string) error {
+	_, err := glamour.NewTermRenderer(glamour.WithStylePath(style))
+	return err
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		style: DefaultStyle,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
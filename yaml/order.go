@@ -0,0 +1,48 @@
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	goyaml "go.yaml.in/yaml/v2"
+)
+
+// canonicalKeys are the top-level keys that come first in the
+// canonical order.
+var canonicalKeys = []string{"apiVersion", "kind", "metadata", "spec"}
+
+// canonicalRank returns the position of key in the canonical order.
+// Keys that are not canonical share the last position.
+func canonicalRank(key any) int {
+	if i := slices.Index(canonicalKeys, fmt.Sprint(key)); i >= 0 {
+		return i
+	}
+	return len(canonicalKeys)
+}
+
+// marshalCanonical marshals resource to YAML with the top-level keys
+// in canonical order. Nested keys are emitted in alphabetical order,
+// like by sigs.k8s.io/yaml.
+func marshalCanonical(resource any) ([]byte, error) {
+	j, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	// The YAML decoder is used for the JSON document, because it
+	// keeps the number types and decodes the top-level object into
+	// an ordered MapSlice.
+	var doc goyaml.MapSlice
+	if err := goyaml.Unmarshal(j, &doc); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(doc, func(i, j int) bool {
+		ri, rj := canonicalRank(doc[i].Key), canonicalRank(doc[j].Key)
+		if ri != rj {
+			return ri < rj
+		}
+		return fmt.Sprint(doc[i].Key) < fmt.Sprint(doc[j].Key)
+	})
+	return goyaml.Marshal(doc)
+}
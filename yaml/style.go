@@ -0,0 +1,135 @@
+package yaml
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/SAP/xp-clifford/erratt"
+
+	nodeyaml "go.yaml.in/yaml/v3"
+)
+
+// foldedHeader matches a line that starts a folded block scalar.
+var foldedHeader = regexp.MustCompile(`(^|: |- )>[-+]?[0-9]?$`)
+
+// blockHeader matches a line that starts a block scalar.
+var blockHeader = regexp.MustCompile(`(^|: |- )[|>][-+]?[0-9]?$`)
+
+// restyle re-encodes the YAML document y with the field comments of
+// r, which may be nil, and the string styles of o.
+func restyle(y []byte, r FieldCommentedYAML, o *options) ([]byte, error) {
+	doc := &nodeyaml.Node{}
+	if err := nodeyaml.Unmarshal(y, doc); err != nil {
+		return nil, erratt.Errorf("cannot parse YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return y, nil
+	}
+	root := doc.Content[0]
+	// The strings are styled first, so that the styles apply to the
+	// commented out fields too.
+	styleStrings(root, o)
+	if r != nil {
+		if err := applyFieldComments(root, r); err != nil {
+			return nil, err
+		}
+	}
+	b, err := encodeNode(doc)
+	if err != nil {
+		return nil, err
+	}
+	if o.foldWidth > 0 {
+		b = foldLines(b, o.foldWidth)
+	}
+	return b, nil
+}
+
+// isFoldable reports whether s can be represented as a folded block
+// scalar that is broken at spaces. Strings without spaces, like
+// encrypted values, cannot be broken and are left as they are.
+func isFoldable(s string) bool {
+	return strings.Contains(s, " ") &&
+		!strings.ContainsAny(s, "\n\r\t") &&
+		!strings.HasPrefix(s, " ") &&
+		!strings.HasSuffix(s, " ")
+}
+
+// styleStrings sets the block styles configured by o for the string
+// scalars of the node tree.
+func styleStrings(node *nodeyaml.Node, o *options) {
+	for _, child := range node.Content {
+		styleStrings(child, o)
+	}
+	if node.Kind != nodeyaml.ScalarNode || node.ShortTag() != "!!str" {
+		return
+	}
+	switch {
+	case o.literalBlocks && strings.Contains(node.Value, "\n"):
+		node.Style = nodeyaml.LiteralStyle
+	case o.foldWidth > 0 && len(node.Value) > o.foldWidth && isFoldable(node.Value):
+		node.Style = nodeyaml.FoldedStyle
+	}
+}
+
+// indentation returns the number of leading spaces of line.
+func indentation(line []byte) int {
+	return len(line) - len(bytes.TrimLeft(line, " "))
+}
+
+// foldLines breaks the lines of the folded block scalars of y at
+// spaces, so that they fit into width characters where possible. A
+// line break between two words of a folded block scalar is read as
+// a single space, so the values do not change.
+func foldLines(y []byte, width int) []byte {
+	out := &bytes.Buffer{}
+	// blockIndent is the indentation of the header of the current
+	// block scalar, or -1 outside of block scalars.
+	blockIndent := -1
+	folded := false
+	for line := range bytes.Lines(y) {
+		content := bytes.TrimSuffix(line, []byte("\n"))
+		if blockIndent >= 0 {
+			if len(bytes.TrimSpace(content)) == 0 || indentation(content) > blockIndent {
+				if folded {
+					foldLine(out, content, width)
+				} else {
+					out.Write(line)
+				}
+				continue
+			}
+			blockIndent = -1
+		}
+		trimmed := bytes.TrimLeft(content, " ")
+		if !bytes.HasPrefix(trimmed, []byte("#")) && blockHeader.Match(content) {
+			blockIndent = indentation(content)
+			folded = foldedHeader.Match(content)
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
+
+// foldLine writes the content line of a folded block scalar to out,
+// broken at single spaces between words.
+func foldLine(out *bytes.Buffer, line []byte, width int) {
+	indent := indentation(line)
+	prefix := line[:indent]
+	words := bytes.Split(line[indent:], []byte(" "))
+	current := bytes.NewBuffer(bytes.Clone(prefix))
+	for i, word := range words {
+		// Only single spaces between words are line breaks, the
+		// empty words of multiple spaces stay on the line.
+		breakable := i > 0 && len(word) > 0 && len(words[i-1]) > 0
+		if breakable && current.Len()+1+len(word) > width && current.Len() > indent {
+			out.Write(current.Bytes())
+			out.WriteString("\n")
+			current = bytes.NewBuffer(bytes.Clone(prefix))
+		} else if i > 0 {
+			current.WriteString(" ")
+		}
+		current.Write(word)
+	}
+	out.Write(current.Bytes())
+	out.WriteString("\n")
+}
@@ -1,10 +1,11 @@
 package yaml
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
 
 	"github.com/SAP/xp-clifford/erratt"
 
@@ -12,27 +13,50 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
-func marshal(resource any) ([]byte, error) {
+func marshal(resource any, o *options) ([]byte, error) {
+	obj := resource
 	if commentedYAML, ok := resource.(*ResourceWithComment); ok {
-		return yaml.Marshal(commentedYAML.Object)
+		obj = commentedYAML.Object
 	}
-	return yaml.Marshal(resource)
+	var b []byte
+	var err error
+	if o.canonicalOrder {
+		b, err = marshalCanonical(obj)
+	} else {
+		b, err = yaml.Marshal(obj)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, ok := resource.(FieldCommentedYAML)
+	if ok && !hasFieldComments(r) {
+		r = nil
+	}
+	if r == nil && !o.literalBlocks && o.foldWidth <= 0 {
+		return b, nil
+	}
+	return restyle(b, r, o)
 }
 
 // Marshal returns the YAML representation of a Kubernetes resource,
 // indented and wrapped with "---" and "..." markers.
-func Marshal(resource any) (string, error) {
-	b, err := marshal(resource)
+func Marshal(resource any, opts ...Option) (string, error) {
+	b, err := marshal(resource, newOptions(opts))
 	if err != nil {
 		return "", err
 	}
-	return wrapResource(resource, string(b)), nil
+	sb := &strings.Builder{}
+	if err := writeDocument(sb, resource, b); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
 }
 
 // MarshalPretty returns a syntax-highlighted YAML string suitable for
 // terminal display.
-func MarshalPretty(resource any) (string, error) {
-	b, err := marshal(resource)
+func MarshalPretty(resource any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	b, err := marshal(resource, o)
 	if err != nil {
 		jsonBytes, err2 := json.Marshal(resource)
 		if err2 == nil {
@@ -41,38 +65,78 @@ func MarshalPretty(resource any) (string, error) {
 		return "", err
 
 	}
-	return glamour.Render(fmt.Sprintf("```yaml\n%s```",
-		wrapResource(resource, string(b))),
-		"dracula")
+	sb := &strings.Builder{}
+	if err := writeDocument(sb, resource, b); err != nil {
+		return "", err
+	}
+	return glamour.Render(fmt.Sprintf("```yaml\n%s```", sb.String()),
+		o.style)
+}
+
+const (
+	documentStart = "---\n"
+	documentEnd   = "...\n"
+)
+
+// stickyWriter writes to w until the first error, which is kept.
+type stickyWriter struct {
+	w   io.Writer
+	err error
 }
 
-func wrapResource(resource any, y string) string {
-	return commentResource(resource,
-		fmt.Sprintf("---\n%s...\n", y))
+func (sw *stickyWriter) Write(p []byte) (int, error) {
+	if sw.err != nil {
+		return 0, sw.err
+	}
+	var n int
+	n, sw.err = sw.w.Write(p)
+	return n, sw.err
 }
 
-func commentResource(resource any, y string) string {
+// writeDocument writes the YAML y of resource to w, wrapped with
+// "---" and "..." markers. If resource is a [CommentedYAML], its
+// comment is written after the document start marker, or the whole
+// document is commented out.
+func writeDocument(w io.Writer, resource any, y []byte) error {
+	sw := &stickyWriter{w: w}
 	if r, ok := resource.(CommentedYAML); ok {
-		if prepend, ok := r.Comment(); ok {
-			return commentYaml(prepend, y)
+		prepend, ok := r.Comment()
+		if ok {
+			writeCommentedDocument(sw, prepend, y)
+			return sw.err
+		}
+		if len(prepend) > 0 {
+			// the comment belongs to the document, so it is
+			// placed after the document start marker
+			_, _ = io.WriteString(sw, documentStart)
+			writeCommentLines(sw, []byte(prepend))
+			_, _ = sw.Write(y)
+			_, _ = io.WriteString(sw, documentEnd)
+			return sw.err
 		}
 	}
-	return y
+	_, _ = io.WriteString(sw, documentStart)
+	_, _ = sw.Write(y)
+	_, _ = io.WriteString(sw, documentEnd)
+	return sw.err
 }
 
-func commentYaml(prepend, y string) string {
-	out := bytes.NewBuffer(nil)
-	if len(prepend) > 0 {
-		fmt.Fprintln(out, "#")
-		scanner := bufio.NewScanner(bytes.NewBufferString(prepend))
-		for scanner.Scan() {
-			fmt.Fprintf(out, "# %s\n", scanner.Text())
-		}
-		fmt.Fprintln(out, "#")
+// writeCommentLines writes each line of text prefixed with "# ".
+func writeCommentLines(w io.Writer, text []byte) {
+	for line := range bytes.Lines(text) {
+		fmt.Fprintf(w, "# %s\n", bytes.TrimSuffix(line, []byte("\n")))
 	}
-	scanner := bufio.NewScanner(bytes.NewBufferString(y))
-	for scanner.Scan() {
-		fmt.Fprintf(out, "# %s\n", scanner.Text())
+}
+
+// writeCommentedDocument writes the commented out YAML document y,
+// preceded by the comment prepend enclosed in "#" lines.
+func writeCommentedDocument(w io.Writer, prepend string, y []byte) {
+	if len(prepend) > 0 {
+		fmt.Fprintln(w, "#")
+		writeCommentLines(w, []byte(prepend))
+		fmt.Fprintln(w, "#")
 	}
-	return out.String()
+	fmt.Fprintf(w, "# %s", documentStart)
+	writeCommentLines(w, y)
+	fmt.Fprintf(w, "# %s", documentEnd)
 }
@@ -0,0 +1,97 @@
+package yaml_test
+
+import (
+	"errors"
+	"io"
+	"maps"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"github.com/crossplane/crossplane-runtime/v2/pkg/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestYaml(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Yaml Suite")
+}
+
+// newResource returns an unstructured resource of the given kind and
+// name. The fields are set at the top level of the resource and
+// replace the defaults.
+func newResource(kind, name string, fields map[string]any) *unstructured.Unstructured {
+	res := &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "test.example.com/v1",
+		"kind":       kind,
+		"metadata":   map[string]any{"name": name},
+	}}
+	maps.Copy(res.Object, fields)
+	return res
+}
+
+// newBucket returns a Bucket with a spec and a data section.
+func newBucket(name string) *unstructured.Unstructured {
+	return newResource("Bucket", name, map[string]any{
+		"spec": map[string]any{"size": int64(1)},
+		"data": map[string]any{"key": "value"},
+	})
+}
+
+// newNoisyBucket returns a Bucket with the fields removed by the
+// default normalization.
+func newNoisyBucket(name string) *unstructured.Unstructured {
+	return newResource("Bucket", name, map[string]any{
+		"metadata": map[string]any{
+			"name":              name,
+			"creationTimestamp": nil,
+			"labels":            map[string]any{},
+			"managedFields":     []any{map[string]any{"manager": "test"}},
+			"annotations": map[string]any{
+				"example.com/id": "1",
+				"team":           "core",
+			},
+		},
+		"spec": map[string]any{
+			"replicas": int64(0),
+			"tags":     []any{map[string]any{"key": "a", "value": ""}},
+			"empty":    map[string]any{"nested": map[string]any{"value": ""}},
+		},
+		"status": map[string]any{"ready": true},
+	})
+}
+
+// newSecret returns a Secret with plain, nested and empty values.
+func newSecret(name string) *unstructured.Unstructured {
+	return newResource("Secret", name, map[string]any{
+		"spec": map[string]any{
+			"user":     "admin",
+			"password": "s3cret",
+			"empty":    "",
+			"credentials": map[string]any{
+				"port":   int64(5432),
+				"tokens": []any{"t1", "t2"},
+			},
+		},
+	})
+}
+
+// decodeAll decodes every document of input.
+func decodeAll(input string) ([]resource.Object, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(input))
+	resources := []resource.Object{}
+	for {
+		res, err := decoder.Decode()
+		if errors.Is(err, io.EOF) {
+			return resources, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, res)
+	}
+}
@@ -0,0 +1,199 @@
+package yaml_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/SAP/xp-clifford/yaml"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Marshal", func() {
+	It("emits the keys in alphabetical order by default", func() {
+		Expect(yaml.Marshal(newBucket("bucket"))).To(Equal(`---
+apiVersion: test.example.com/v1
+data:
+  key: value
+kind: Bucket
+metadata:
+  name: bucket
+spec:
+  size: 1
+...
+`))
+	})
+	It("emits the canonical keys first in canonical order", func() {
+		Expect(yaml.Marshal(newBucket("bucket"), yaml.WithCanonicalOrder())).To(Equal(`---
+apiVersion: test.example.com/v1
+kind: Bucket
+metadata:
+  name: bucket
+spec:
+  size: 1
+data:
+  key: value
+...
+`))
+	})
+})
+
+var _ = Describe("Marshal with field comments", func() {
+	var res *yaml.ResourceWithComment
+	BeforeEach(func() {
+		res = yaml.NewResourceWithComment(&unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "test.example.com/v1",
+			"kind":       "Bucket",
+			"metadata":   map[string]any{"name": "bucket"},
+			"spec": map[string]any{
+				"forProvider": map[string]any{
+					"quota":  "free",
+					"region": "eu10",
+					"tags":   []any{map[string]any{"key": "team", "value": "core"}},
+				},
+			},
+		}})
+	})
+	It("places field comments on the line of the field", func() {
+		res.AddFieldComment("spec.forProvider.quota", "could not resolve quota plan")
+		res.AddFieldComment("spec.forProvider", "generated")
+		res.AddFieldComment("spec.forProvider.tags.0", "first tag")
+		Expect(yaml.Marshal(res)).To(Equal(`---
+apiVersion: test.example.com/v1
+kind: Bucket
+metadata:
+  name: bucket
+spec:
+  forProvider: # generated
+    quota: free # could not resolve quota plan
+    region: eu10
+    tags:
+    - key: team # first tag
+      value: core
+...
+`))
+	})
+	It("comments out fields", func() {
+		res.CommentOutField("spec.forProvider.quota")
+		res.CommentOutField("spec.forProvider.tags")
+		res.CommentOutField("metadata.name")
+		res.CommentOutField("spec.forProvider.missing")
+		Expect(yaml.Marshal(res)).To(Equal(`---
+apiVersion: test.example.com/v1
+kind: Bucket
+# metadata:
+#   name: bucket
+spec:
+  forProvider:
+    # quota: free
+    region: eu10
+    # tags:
+    # - key: team
+    #   value: core
+...
+`))
+	})
+	It("keeps the field comments of commented out fields", func() {
+		res.AddFieldComment("spec.forProvider.region", "unsupported")
+		res.CommentOutField("spec.forProvider")
+		Expect(yaml.Marshal(res)).To(Equal(`---
+apiVersion: test.example.com/v1
+kind: Bucket
+metadata:
+  name: bucket
+# spec:
+#   forProvider:
+#     quota: free
+#     region: eu10 # unsupported
+#     tags:
+#     - key: team
+#       value: core
+...
+`))
+	})
+})
+
+var _ = Describe("Marshal with commented out fields in nested fields", func() {
+	It("comments out the parents left empty", func() {
+		res := yaml.NewResourceWithComment(newResource("Bucket", "bucket", map[string]any{
+			"spec": map[string]any{
+				"forProvider": map[string]any{"region": "eu10"},
+			},
+			"status": map[string]any{"ready": true},
+		}))
+		res.CommentOutField("spec.forProvider.region")
+		Expect(yaml.Marshal(res)).To(Equal(`---
+apiVersion: test.example.com/v1
+kind: Bucket
+metadata:
+  name: bucket
+# spec:
+#   forProvider:
+#     region: eu10
+status:
+  ready: true
+...
+`))
+	})
+})
+
+var _ = Describe("An Encoder", func() {
+	It("writes the documents like Marshal", func() {
+		commented := yaml.NewResourceWithComment(newBucket("bucket"))
+		commented.SetComment("do not apply")
+		sb := &strings.Builder{}
+		enc := yaml.NewEncoder(sb, yaml.WithCanonicalOrder())
+		Expect(enc.Encode(newBucket("bucket"))).To(Succeed())
+		Expect(enc.Encode(commented)).To(Succeed())
+		Expect(sb.String()).To(BeEmpty())
+		Expect(enc.Flush()).To(Succeed())
+
+		first, err := yaml.Marshal(newBucket("bucket"), yaml.WithCanonicalOrder())
+		Expect(err).NotTo(HaveOccurred())
+		second, err := yaml.Marshal(commented, yaml.WithCanonicalOrder())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sb.String()).To(Equal(first + second))
+	})
+})
+
+var _ = Describe("Marshal with block styles", func() {
+	var res *unstructured.Unstructured
+	BeforeEach(func() {
+		res = &unstructured.Unstructured{Object: map[string]any{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"data": map[string]any{
+				"script": "#!/bin/sh\n\techo hello\n",
+				"long":   strings.Repeat("word ", 12) + "end",
+				"spaced": "trailing \nspace",
+			},
+		}}
+	})
+	It("renders multi-line strings as literal blocks and folds long strings", func() {
+		y, err := yaml.Marshal(res, yaml.WithLiteralBlocks(), yaml.WithFoldWidth(30))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(y).To(Equal(`---
+apiVersion: v1
+data:
+  long: >-
+    word word word word word
+    word word word word word
+    word word end
+  script: |
+    #!/bin/sh
+    	echo hello
+  spaced: "trailing \nspace"
+kind: ConfigMap
+...
+`))
+	})
+	It("keeps the values of the strings", func() {
+		y, err := yaml.Marshal(res, yaml.WithLiteralBlocks(), yaml.WithFoldWidth(30))
+		Expect(err).NotTo(HaveOccurred())
+		read, err := yaml.NewDecoder(strings.NewReader(y)).Decode()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(read).To(Equal(res))
+	})
+})